@@ -0,0 +1,87 @@
+//clock.js
+import { socket } from './socket.js';
+
+// Time sync layout: [1 byte message id = 3][8 bytes send time f64 ms]
+const CLIENT_TIME_SYNC = 3;
+
+// Probes sent right after connecting, then one every SYNC_INTERVAL
+const SYNC_BURST = 5;
+const SYNC_BURST_SPACING = 100;
+const SYNC_INTERVAL = 10000;
+// The sample with the shortest round trip among the last MAX_SAMPLES wins,
+// queueing delay only ever makes the offset estimate worse
+const MAX_SAMPLES = 8;
+
+// Other players are drawn this far in the past so there are snapshots on both sides
+export const INTERPOLATION_DELAY = 100;
+
+let samples = [];
+let best = null;
+let tickMs = 0;
+let syncTimer = null;
+
+function sendTimeSync() {
+  if (socket && socket.readyState === WebSocket.OPEN) {
+    const view = new DataView(new ArrayBuffer(9));
+    view.setUint8(0, CLIENT_TIME_SYNC);
+    view.setFloat64(1, performance.now(), true);
+    socket.send(view.buffer);
+  }
+}
+
+// startClockSync probes the server clock, call it once the socket is open.
+export function startClockSync(tickRate) {
+  tickMs = 1000 / tickRate;
+  stopClockSync();
+
+  // A reconnect may take another route, old samples say nothing about it
+  samples = [];
+  best = null;
+
+  for (let i = 0; i < SYNC_BURST; i++) {
+    setTimeout(sendTimeSync, i * SYNC_BURST_SPACING);
+  }
+  syncTimer = setInterval(sendTimeSync, SYNC_INTERVAL);
+}
+
+export function stopClockSync() {
+  if (syncTimer !== null) {
+    clearInterval(syncTimer);
+    syncTimer = null;
+  }
+}
+
+// addTimeSample takes a decoded time_sync answer.
+export function addTimeSample(data) {
+  const received = performance.now();
+  const rtt = (received - data.clientTime) - (data.serverSent - data.serverReceived);
+  const offset = ((data.serverReceived - data.clientTime) + (data.serverSent - received)) / 2;
+
+  samples.push({ rtt, offset, tick: data.tick, tickAt: data.tickAt });
+  if (samples.length > MAX_SAMPLES) {
+    samples.shift();
+  }
+  best = samples.reduce((a, b) => (b.rtt < a.rtt ? b : a));
+}
+
+export function clockSynced() {
+  return best !== null;
+}
+
+// serverNow estimates the server clock, in ms.
+export function serverNow() {
+  return performance.now() + (best ? best.offset : 0);
+}
+
+// serverTick estimates the tick, fractional, the server simulates at server time t.
+export function serverTick(t = serverNow()) {
+  if (!best || tickMs === 0) {
+    return 0;
+  }
+  return best.tick + (t - best.tickAt) / tickMs;
+}
+
+// renderTick is the tick to draw the world at, INTERPOLATION_DELAY behind the server.
+export function renderTick() {
+  return serverTick(serverNow() - INTERPOLATION_DELAY);
+}
@@ -2,45 +2,432 @@
 //decode.js
 const decoder = new TextDecoder()
 
-export function decode(buf) {
-  const view = new DataView(buf);
-  let offset = 0;
+function decodeScoreboard(view, offset) {
+  const scores = [];
+  while (offset < view.byteLength) {
+    scores.push({
+      id: view.getUint32(offset, true),
+      kills: view.getUint32(offset + 4, true),
+      deaths: view.getUint32(offset + 8, true),
+      assists: view.getUint32(offset + 12, true),
+      objective: view.getInt32(offset + 16, true),
+      timeAlive: view.getFloat32(offset + 20, true),
+      ping: view.getUint16(offset + 24, true),
+    });
+    offset += 26;
+  }
+  return scores;
+}
 
-  // Step 1: Extract message type
-  const typeLen = view.getUint32(offset, true);
-  offset += 4;
+const PHASES = ["waiting", "countdown", "in_progress", "overtime", "results", "reset"];
 
-  const typeBytes = new Uint8Array(buf, offset, typeLen);
-  const messageType = new TextDecoder().decode(typeBytes);
-  offset += typeLen;
+function decodeMatchPhase(view, offset) {
+  return {
+    phase: PHASES[view.getUint8(offset)] || "unknown",
+    remainingMs: view.getUint32(offset + 1, true),
+  };
+}
 
-  const TYPE_MAP = ["character", "enemy", "item"];
+function decodePlayerState(view, offset) {
+  return {
+    id: view.getUint32(offset, true),
+    state: PLAYER_STATES[view.getUint8(offset + 4)] || "unknown",
+  };
+}
 
-  const objects = [];
-  while (offset < buf.byteLength) {
-    const id = view.getUint32(offset, true);
+// Profile layout: [1 color][1 skin][1 avatar][1 name length][name]
+function decodeProfile(view, offset) {
+  const nameLen = view.getUint8(offset + 3);
+  const nameBytes = new Uint8Array(view.buffer, view.byteOffset + offset + 4, nameLen);
+  return {
+    profile: {
+      color: view.getUint8(offset),
+      skin: view.getUint8(offset + 1),
+      avatar: view.getUint8(offset + 2),
+      name: new TextDecoder().decode(nameBytes),
+    },
+    size: 4 + nameLen,
+  };
+}
+
+function decodePlayerProfile(view, offset) {
+  return {
+    id: view.getUint32(offset, true),
+    ...decodeProfile(view, offset + 4).profile,
+  };
+}
+
+function decodeInputAck(view, offset) {
+  return {
+    sequence: view.getUint32(offset, true),
+    position: { x: view.getFloat32(offset + 4, true), y: view.getFloat32(offset + 8, true) },
+    velocity: { x: view.getFloat32(offset + 12, true), y: view.getFloat32(offset + 16, true) },
+  };
+}
+
+function decodePing(view, offset) {
+  return {
+    rtt: view.getUint16(offset, true),
+    jitter: view.getUint16(offset + 2, true),
+  };
+}
+
+function decodeSession(view, offset) {
+  return {
+    graceMs: view.getUint32(offset, true),
+    token: new TextDecoder().decode(new Uint8Array(view.buffer, offset + 4)),
+  };
+}
+
+// Time sync layout: [8 client send f64][8 server receive f64][8 server send f64]
+// [4 tick][8 time the tick was simulated f64], all times in ms
+function decodeTimeSync(view, offset) {
+  return {
+    clientTime: view.getFloat64(offset, true),
+    serverReceived: view.getFloat64(offset + 8, true),
+    serverSent: view.getFloat64(offset + 16, true),
+    tick: view.getUint32(offset + 24, true),
+    tickAt: view.getFloat64(offset + 28, true),
+  };
+}
+
+function decodeIDs(view, offset) {
+  const ids = [];
+  while (offset < view.byteLength) {
+    ids.push(view.getUint32(offset, true));
     offset += 4;
+  }
+  return ids;
+}
+
+const TYPE_MAP = ["character", "enemy", "item", "flag", "pickup"];
+
+// decodeObject reads one full object record and returns it with the offset after it.
+function decodeObject(view, offset) {
+  const id = view.getUint32(offset, true);
+  offset += 4;
+
+  const typeCode = view.getUint8(offset);
+  offset += 1;
+
+  const x = view.getFloat32(offset, true);
+  offset += 4;
 
-    const typeCode = view.getUint8(offset);
+  const y = view.getFloat32(offset, true);
+  offset += 4;
+
+  // Players and flags carry their team
+  let team = 0;
+  if (TEAM_TYPES.has(typeCode)) {
+    team = view.getUint8(offset);
     offset += 1;
+  }
 
-    const x = view.getFloat32(offset, true);
-    offset += 4;
+  let state = null;
+  if (typeCode === PLAYER_TYPE) {
+    state = PLAYER_STATES[view.getUint8(offset)] || "unknown";
+    offset += 1;
+  }
+
+  let pickup = null;
+  if (typeCode === PICKUP_TYPE) {
+    pickup = {
+      kind: view.getUint8(offset),
+      active: view.getUint8(offset + 1) === 1,
+    };
+    offset += 2;
+  }
+
+  const obj = {
+    id,
+    type: TYPE_MAP[typeCode] || "unknown",
+    position: { x, y },
+    team,
+    state,
+    pickup,
+    children: []
+  };
+  return { obj, offset };
+}
+
+// decodeObjects reads full object records up to the end of the payload.
+// Roster messages stop after the first record, which is followed by a profile.
+function decodeObjects(view, offset, roster) {
+  const objects = [];
+  while (offset < view.byteLength) {
+    const decoded = decodeObject(view, offset);
+    offset = decoded.offset;
+    objects.push(decoded.obj);
+
+    if (roster) {
+      objects[0].profile = decodeProfile(view, offset).profile;
+      break;
+    }
+  }
+
+  return objects;
+}
+
+// Full state layout: [2 player count][player record + profile per player][object records]
+function decodeFullState(view, offset) {
+  const count = view.getUint16(offset, true);
+  offset += 2;
+
+  const roster = [];
+  for (let i = 0; i < count; i++) {
+    const decoded = decodeObject(view, offset);
+    const profile = decodeProfile(view, decoded.offset);
+    decoded.obj.profile = profile.profile;
+    roster.push(decoded.obj);
+    offset = decoded.offset + profile.size;
+  }
+
+  return { roster, objects: decodeObjects(view, offset, false) };
+}
 
-    const y = view.getFloat32(offset, true);
+function decodeIDList(view, offset) {
+  const count = view.getUint16(offset, true);
+  offset += 2;
+
+  const ids = [];
+  for (let i = 0; i < count; i++) {
+    ids.push(view.getUint32(offset, true));
     offset += 4;
+  }
+  return { ids, offset };
+}
+
+// Snapshot layout: [1 flags][2 left count][4 id per left object]
+// [2 despawned count][4 id per despawned object][object records]
+function decodeSnapshot(view, offset) {
+  const keyframe = (view.getUint8(offset) & 1) === 1;
+  const left = decodeIDList(view, offset + 1);
+  const despawned = decodeIDList(view, left.offset);
+
+  return {
+    keyframe,
+    left: left.ids,
+    despawned: despawned.ids,
+    objects: decodeObjects(view, despawned.offset, false),
+  };
+}
+
+// BitReader reads values packed least significant bit first.
+class BitReader {
+  constructor(view, offset) {
+    this.view = view;
+    this.bit = offset * 8;
+  }
+
+  readBits(n) {
+    let value = 0;
+    for (let i = 0; i < n; i++) {
+      const byte = this.view.getUint8(this.bit >> 3);
+      value += ((byte >> (this.bit & 7)) & 1) * 2 ** i;
+      this.bit++;
+    }
+    return value;
+  }
+
+  readBool() {
+    return this.readBits(1) === 1;
+  }
+
+  // Groups of 7 bits, each followed by a bit telling whether another follows
+  readVarint() {
+    let value = 0;
+    for (let shift = 0; ; shift += 7) {
+      value += this.readBits(7) * 2 ** shift;
+      if (!this.readBool()) {
+        return value;
+      }
+    }
+  }
+}
+
+// How compact snapshots quantize positions, set by the welcome message
+let quantizer = null;
+
+function dequantize(value, bits, max) {
+  return (value / (2 ** bits - 1)) * max;
+}
+
+// Welcome layout: [1 version][2 tick rate][1 flags]
+// then with compact snapshots [4 max x][4 max y][1 x bits][1 y bits]
+function decodeWelcome(view, offset) {
+  const welcome = {
+    version: view.getUint8(offset),
+    tickRate: view.getUint16(offset + 1, true),
+    compact: false,
+  };
 
-    objects.push({
-      id,
-      type: TYPE_MAP[typeCode] || "unknown",
-      position: { x, y },
+  if (offset + 3 < view.byteLength && (view.getUint8(offset + 3) & 1) === 1) {
+    welcome.compact = true;
+    quantizer = {
+      maxX: view.getFloat32(offset + 4, true),
+      maxY: view.getFloat32(offset + 8, true),
+      xBits: view.getUint8(offset + 12),
+      yBits: view.getUint8(offset + 13),
+    };
+  }
+  return welcome;
+}
+
+const FIELD_POSITION = 1;
+const FIELD_TEAM = 2;
+const FIELD_STATE = 4;
+const FIELD_PICKUP = 8;
+
+// Compact snapshots are bit packed and only carry the fields that changed,
+// fields that did not change are left null.
+function decodeCompactSnapshot(view, offset) {
+  const r = new BitReader(view, offset);
+  const keyframe = r.readBool();
+
+  const readIDs = () => {
+    const ids = [];
+    const count = r.readVarint();
+    for (let i = 0; i < count; i++) {
+      ids.push(r.readVarint());
+    }
+    return ids;
+  };
+  const left = readIDs();
+  const despawned = readIDs();
+
+  const objects = [];
+  const count = r.readVarint();
+  for (let i = 0; i < count; i++) {
+    const obj = {
+      id: r.readVarint(),
+      type: null,
+      position: null,
+      team: null,
+      state: null,
+      pickup: null,
       children: []
-    });
+    };
+    obj.type = TYPE_MAP[r.readBits(3)] || "unknown";
+    const mask = r.readBits(4);
+
+    if (mask & FIELD_POSITION) {
+      obj.position = {
+        x: dequantize(r.readBits(quantizer.xBits), quantizer.xBits, quantizer.maxX),
+        y: dequantize(r.readBits(quantizer.yBits), quantizer.yBits, quantizer.maxY),
+      };
+    }
+    if (mask & FIELD_TEAM) {
+      obj.team = r.readBits(4);
+    }
+    if (mask & FIELD_STATE) {
+      obj.state = PLAYER_STATES[r.readBits(3)] || "unknown";
+    }
+    if (mask & FIELD_PICKUP) {
+      obj.pickup = { kind: r.readBits(4), active: r.readBool() };
+    }
+    objects.push(obj);
+  }
+
+  return { keyframe, left, despawned, objects };
+}
+
+const PAYLOAD_DECODERS = {
+  welcome: decodeWelcome,
+  leave_view: decodeIDs,
+  scoreboard: decodeScoreboard,
+  match_phase: decodeMatchPhase,
+  player_state: decodePlayerState,
+  player_profile: decodePlayerProfile,
+  input_ack: decodeInputAck,
+  snapshot: decodeSnapshot,
+  compact_snapshot: decodeCompactSnapshot,
+  full_state: decodeFullState,
+  ping: decodePing,
+  session: decodeSession,
+  time_sync: decodeTimeSync,
+};
+
+// Roster messages carry a single player followed by its profile
+const ROSTER_MESSAGES = new Set(["player_joined", "player_left"]);
+
+const OBJECT_MESSAGES = new Set(["position_update", "player_joined", "player_left", "enter_view"]);
+const TEAM_TYPES = new Set([2, 3]);
+const PLAYER_TYPE = 2;
+const PICKUP_TYPE = 4;
+
+export const PROTOCOL_VERSION = 5;
+
+// Header: [1 version][2 type][4 tick][4 payload length]
+const HEADER_SIZE = 11;
+
+// Indexed by message type ID, must match protocol.MsgType on the server
+const MESSAGE_TYPES = [
+  "unknown",
+  "welcome",
+  "position_update",
+  "player_joined",
+  "player_left",
+  "enter_view",
+  "leave_view",
+  "scoreboard",
+  "match_phase",
+  "player_died",
+  "team_changed",
+  "team_scores",
+  "flag_event",
+  "chat_message",
+  "pickup_taken",
+  "pickup_respawned",
+  "status_effects",
+  "ability_state",
+  "arena",
+  "player_state",
+  "player_profile",
+  "profile_error",
+  "spectate_target",
+  "input_ack",
+  "snapshot",
+  "compact_snapshot",
+  "full_state",
+  "ping",
+  "session",
+  "time_sync",
+];
+
+export const PLAYER_STATES = ["idle", "moving", "attacking", "stunned", "dead", "respawning"];
+
+export function decode(buf) {
+  const view = new DataView(buf);
+
+  // Step 1: Read the header
+  const version = view.getUint8(0);
+  if (version !== PROTOCOL_VERSION) {
+    return { type: "unknown", tick: 0, data: null };
+  }
+  const messageType = MESSAGE_TYPES[view.getUint16(1, true)] || "unknown";
+  const tick = view.getUint32(3, true);
+  let offset = HEADER_SIZE;
+
+  const payloadDecoder = PAYLOAD_DECODERS[messageType];
+  if (payloadDecoder) {
+    return {
+      type: messageType,
+      tick,
+      data: payloadDecoder(view, offset)
+    };
+  }
+
+  if (!OBJECT_MESSAGES.has(messageType)) {
+    return {
+      type: messageType,
+      tick,
+      data: new DataView(buf, offset)
+    };
   }
 
   return {
     type: messageType,
-    data: objects
+    tick,
+    data: decodeObjects(view, offset, ROSTER_MESSAGES.has(messageType))
   };
 }
-
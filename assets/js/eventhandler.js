@@ -1,5 +1,8 @@
 //eventhandler.js
 import { createAnimator } from './animation.js';
+import { acknowledge } from './input.js';
+import { setResumeToken } from './socket.js';
+import { addTimeSample, startClockSync } from './clock.js';
 const eventsMap = new Map();
 
 function PlayerLeft(data,players,game_container){
@@ -24,6 +27,11 @@ function PlayerJoined(data, players, game_container) {
     newPlayer.id = type + playerId;
     newPlayer.classList.add(type);
     newPlayer.classList.add('other');
+    if (data.profile) {
+      newPlayer.title = data.profile.name;
+      newPlayer.dataset.color = data.profile.color;
+      newPlayer.dataset.skin = data.profile.skin;
+    }
     game_container.appendChild(newPlayer);
 
     const newAnimation = createAnimator(newPlayer);
@@ -45,6 +53,7 @@ function PositionUpdate(data, players, game_container) {
     if (!players.has(playerId)) {
       const newPlayer = document.createElement('div');
       newPlayer.id = type + playerId;
+      newPlayer.dataset.objectId = playerId;
       newPlayer.classList.add(type);
       newPlayer.classList.add('other');
       game_container.appendChild(newPlayer);
@@ -60,18 +69,113 @@ function PositionUpdate(data, players, game_container) {
 }
 
 
+function LeaveView(ids, players, game_container) {
+  ids.forEach((id) => {
+    const animator = players.get(id);
+    if (!animator) {
+      return;
+    }
+    players.delete(id);
+
+    const sprite = game_container.querySelector(`[data-object-id="${id}"]`);
+    if (sprite) {
+      game_container.removeChild(sprite);
+    }
+  });
+}
+
+
+// Snapshot applies a delta against the last snapshot we acknowledged. A
+// keyframe lists everything in view, so objects missing from it are gone.
+function Snapshot(data, players, game_container) {
+  const myID = Number(window.PLAYERID);
+  const left = data.left.concat(data.despawned);
+
+  if (data.keyframe) {
+    const visible = new Set(data.objects.map((obj) => obj.id));
+    players.forEach((_, id) => {
+      if (id !== myID && !visible.has(id)) {
+        left.push(id);
+      }
+    });
+  }
+
+  LeaveView(left, players, game_container);
+  // Compact snapshots leave position out when only other fields changed
+  PositionUpdate(data.objects.filter((obj) => obj.position), players, game_container);
+}
+
+// FullState replaces everything we know about the world
+function FullState(data, players, game_container) {
+  window.ROSTER = data.roster;
+  Snapshot({ keyframe: true, left: [], despawned: [], objects: data.objects }, players, game_container);
+}
+
+// Ping is our own round trip and jitter in milliseconds
+function Ping(data) {
+  window.PING = data;
+}
+
+// Session carries the token to resume with when the connection drops
+function Session(data) {
+  setResumeToken(data.token, data.graceMs);
+}
+
+function Welcome(data) {
+  window.SERVER_INFO = data;
+  startClockSync(data.tickRate);
+}
+
+// TimeSync answers one of our clock probes
+function TimeSync(data) {
+  addTimeSample(data);
+}
+
+
+function Scoreboard(data) {
+  window.SCOREBOARD = data;
+}
+
+// InputAck drops acknowledged inputs, the rest stay queued for replay
+function InputAck(data) {
+  acknowledge(data.sequence);
+}
+
+function MatchPhase(data) {
+  window.MATCH_PHASE = data;
+}
+
+
 eventsMap.set('player_left',PlayerLeft);
 eventsMap.set('player_joined',PlayerJoined);
 eventsMap.set('position_update',PositionUpdate);
+eventsMap.set('enter_view',PositionUpdate);
+eventsMap.set('leave_view',LeaveView);
+eventsMap.set('scoreboard',Scoreboard);
+eventsMap.set('match_phase',MatchPhase);
+eventsMap.set('input_ack',InputAck);
+eventsMap.set('snapshot',Snapshot);
+eventsMap.set('compact_snapshot',Snapshot);
+eventsMap.set('welcome',Welcome);
+eventsMap.set('full_state',FullState);
+eventsMap.set('ping',Ping);
+eventsMap.set('session',Session);
+eventsMap.set('time_sync',TimeSync);
 
 
 export function HandleEvent(e,players,game_container){
   const type = e.type;
   const data = e.data;
 
-  if(type != "position_update"){
+  if(type != "position_update" && type != "scoreboard" && type != "enter_view" && type != "leave_view" && type != "input_ack" && type != "snapshot" && type != "compact_snapshot" && type != "ping" && type != "session" && type != "time_sync"){
     console.log(type);
     console.log(data);
   }
-  eventsMap.get(type)(data,players,game_container);
+
+  const handler = eventsMap.get(type);
+  if(!handler){
+    console.log(`Unhandled event type: ${type}`);
+    return;
+  }
+  handler(data,players,game_container);
 }
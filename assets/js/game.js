@@ -5,6 +5,7 @@ import { createAnimator } from './animation.js';
 //import  init, { decode } from '../../wasm/decoder/pkg/decoder.js';
 import { decode } from './decode.js';
 import { HandleEvent } from './eventhandler.js';
+import { stopClockSync } from './clock.js';
 //import "./testing.js";
 
 const game_container = document.getElementById("game-container");
@@ -29,6 +30,44 @@ const myID = Number(player_id);
 
 setupInput();
 
+function sendViewport() {
+  if (socket && socket.readyState === WebSocket.OPEN) {
+    socket.send(JSON.stringify({
+      type: "viewport",
+      data: { width: window.innerWidth, height: window.innerHeight },
+    }));
+  }
+}
+
+window.addEventListener("resize", sendViewport);
+
+// Snapshot ack layout: [1 byte message id = 2][4 bytes tick]
+const CLIENT_SNAPSHOT_ACK = 2;
+
+const SNAPSHOT_MESSAGES = new Set(["snapshot", "compact_snapshot", "full_state"]);
+
+function requestFullState() {
+  if (socket && socket.readyState === WebSocket.OPEN) {
+    socket.send(JSON.stringify({ type: "full_state_request", data: {} }));
+  }
+}
+
+// Background tabs are throttled, resync when we come back
+document.addEventListener("visibilitychange", () => {
+  if (document.visibilityState === "visible") {
+    requestFullState();
+  }
+});
+
+function sendSnapshotAck(tick) {
+  if (socket && socket.readyState === WebSocket.OPEN) {
+    const view = new DataView(new ArrayBuffer(5));
+    view.setUint8(0, CLIENT_SNAPSHOT_ACK);
+    view.setUint32(1, tick, true);
+    socket.send(view.buffer);
+  }
+}
+
 setupSocket( token ,
   (e) => {
     //log.textContent += "Server: " + e.data + "\n";
@@ -53,12 +92,28 @@ setupSocket( token ,
     
     HandleEvent(event, players, game_container);
 
+    // Later snapshots are encoded against the last one we acknowledge
+    if (SNAPSHOT_MESSAGES.has(event.type)) {
+      sendSnapshotAck(event.tick);
+    }
+
+    // A compact delta without a position for an object we never saw means we are out of sync
+    if (event.type === "compact_snapshot" && event.data.objects.some((obj) => !obj.position && !players.has(obj.id))) {
+      requestFullState();
+    }
+
 
   },
   () => {
     log.textContent += "Status: Connected\n";
     log.textContent += `${myID} ${token}`;
 
+    // On a resume our sprite is still there
+    if (players.has(myID)) {
+      sendViewport();
+      return;
+    }
+
     const newPlayer = document.createElement('div');
     newPlayer.id="character" + myID;
     newPlayer.classList.add("character");
@@ -66,9 +121,11 @@ setupSocket( token ,
     game_container.appendChild(newPlayer);
     const newAnimation = createAnimator(newPlayer);
     players.set(myID,newAnimation);
+    sendViewport();
     //startSendingKeys();
   },
   () => {
     //stopSendingKeys();
+    stopClockSync();
   }
 );
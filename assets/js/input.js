@@ -12,6 +12,11 @@ const temps = new Set();
 export function setupInput() {
     document.addEventListener("keydown", (e) => {
         const key = e.key.toLowerCase();
+        if (key === " " && !e.repeat) {
+            pressedKeys.add(key);
+            sendMovementCommand();
+            return;
+        }
         if (["w", "a", "s", "d"].includes(key)) {
             let opposite = opposites.get(key)
             if (pressedKeys.has(opposite)) {
@@ -36,35 +41,59 @@ export function setupInput() {
   });
 }
 
+// Binary input layout: [1 id][4 sequence][2 buttons][4 move x][4 move y][4 aim]
+const CLIENT_INPUT = 1;
+const INPUT_SIZE = 19;
+const BUTTON_PRIMARY = 1 << 0;
+
+let sequence = 0;
+
+// Inputs sent but not yet acknowledged, replayed on top of the
+// authoritative state to reconcile the predicted position
+const pendingInputs = [];
+
+export function acknowledge(ackedSequence) {
+    while (pendingInputs.length > 0 && pendingInputs[0].sequence <= ackedSequence) {
+        pendingInputs.shift();
+    }
+    return pendingInputs;
+}
+
+function encodeInput(buttons, moveX, moveY, aim) {
+    const buf = new ArrayBuffer(INPUT_SIZE);
+    const view = new DataView(buf);
+    sequence = (sequence + 1) >>> 0;
+
+    view.setUint8(0, CLIENT_INPUT);
+    view.setUint32(1, sequence, true);
+    view.setUint16(5, buttons, true);
+    view.setFloat32(7, moveX, true);
+    view.setFloat32(11, moveY, true);
+    view.setFloat32(15, aim, true);
+
+    pendingInputs.push({ sequence, moveX, moveY, sentAt: performance.now() });
+    return buf;
+}
+
 export function sendMovementCommand() {
-    let direction = "move_stop";  
-  
-    if (pressedKeys.has("w") && pressedKeys.has("a")) {
-        direction = "move_up_left";  
-  } else if (pressedKeys.has("w") && pressedKeys.has("d")) {
-        direction = "move_up_right";
-    } else if (pressedKeys.has("s") && pressedKeys.has("a")) {
-        direction = "move_down_left";
-    } else if (pressedKeys.has("s") && pressedKeys.has("d")) {
-        direction = "move_down_right";
-    } else if (pressedKeys.has("w")) {
-        direction = "move_up";
-    } else if (pressedKeys.has("s")) {
-        direction = "move_down";
-    } else if (pressedKeys.has("a")) {
-        direction = "move_left";
-    } else if (pressedKeys.has("d")) {
-        direction = "move_right";
+    let x = 0;
+    let y = 0;
+    if (pressedKeys.has("a")) x -= 1;
+    if (pressedKeys.has("d")) x += 1;
+    if (pressedKeys.has("w")) y -= 1;
+    if (pressedKeys.has("s")) y += 1;
+
+    // Diagonals are normalised so they are not faster than straight lines
+    const length = Math.hypot(x, y);
+    if (length > 0) {
+        x /= length;
+        y /= length;
     }
 
+    const buttons = pressedKeys.has(" ") ? BUTTON_PRIMARY : 0;
+
     if (socket.readyState === WebSocket.OPEN) {
-        const msg = {
-            type: "input_movement",
-            data: {
-                direction: direction, 
-            },
-        };
-        socket.send(JSON.stringify(msg));
+        socket.send(encodeInput(buttons, x, y, 0));
     } else {
         console.log("Cant send movement command: Socket not open.");
     }
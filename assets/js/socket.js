@@ -1,15 +1,68 @@
 //socket.js
+import { PROTOCOL_VERSION } from './decode.js';
+
+// Close code the server uses when it speaks another protocol version
+const CLOSE_VERSION_MISMATCH = 4001;
+// Close code for players disconnected after sending no input for too long
+const CLOSE_IDLE = 4002;
+// Close code for a resume that came after the server let the player go
+const CLOSE_SESSION_EXPIRED = 4003;
+
+// Delay before the first reconnect attempt, doubled after each failure
+const RECONNECT_DELAY = 250;
+const MAX_RECONNECT_DELAY = 4000;
+
 let socket; // don't export it directly
 
+// The server hands out a new resume token on every connection
+let resume = null;
+
+export function setResumeToken(token, graceMs) {
+  resume = { token, graceMs };
+}
+
 export function setupSocket(token,onMessageCallback, onOpenCallback, onCloseCallback) {
   if(token === null)
     return;
-  socket = new WebSocket("ws://" + location.host + `/game?token=${token}`);
-  socket.binaryType = "arraybuffer";
 
-  socket.onmessage = onMessageCallback;
-  socket.onopen = onOpenCallback;
-  socket.onclose = onCloseCallback;
+  let deadline = 0;
+  let delay = RECONNECT_DELAY;
+
+  const connect = (query) => {
+    socket = new WebSocket("ws://" + location.host + `/game?${query}`);
+    socket.binaryType = "arraybuffer";
+
+    socket.onmessage = onMessageCallback;
+    socket.onopen = (e) => {
+      // The server expects the hello before anything else
+      socket.send(JSON.stringify({ type: "hello", data: { version: PROTOCOL_VERSION } }));
+      deadline = 0;
+      delay = RECONNECT_DELAY;
+      onOpenCallback(e);
+    };
+    socket.onclose = (e) => {
+      if (e.code === CLOSE_VERSION_MISMATCH) {
+        alert("This client is out of date, please reload the page. " + e.reason);
+      } else if (e.code === CLOSE_IDLE) {
+        alert("You were disconnected for being idle.");
+      } else if (e.code === CLOSE_SESSION_EXPIRED) {
+        location.reload();
+        return;
+      } else if (resume !== null) {
+        // The server keeps us in the game for graceMs, keep trying until then
+        if (deadline === 0) {
+          deadline = Date.now() + resume.graceMs;
+        }
+        if (Date.now() < deadline) {
+          setTimeout(() => connect(`resume=${resume.token}`), delay);
+          delay = Math.min(delay * 2, MAX_RECONNECT_DELAY);
+        }
+      }
+      onCloseCallback(e);
+    };
+  };
+
+  connect(`token=${token}`);
 }
 
 export { socket }; // export it after being set
@@ -0,0 +1,335 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"game/gamebase"
+)
+
+// Duration reads durations like "5s" or "1m30s" from JSON.
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"5s\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// Server values are fixed for the lifetime of the process,
+// except ViewRadius which is applied on reload.
+type Server struct {
+	FixedTPS       float64  `json:"fixed_tps"`
+	TargetFPS      int      `json:"target_fps"`
+	MaxSpectators  int      `json:"max_spectators"`
+	SpectatorDelay Duration `json:"spectator_delay"`
+	ViewRadius     float32  `json:"view_radius"` // pixels, until a client reports its viewport
+
+	// Lag compensation
+	HistoryTicks int      `json:"history_ticks"`
+	MaxRewind    Duration `json:"max_rewind"`
+
+	// Quantized, bit packed snapshots instead of raw float positions
+	CompactSnapshots bool `json:"compact_snapshots"`
+
+	// How often every client is resent the whole state, zero only on request
+	FullStateInterval Duration `json:"full_state_interval"`
+
+	// Outbound queue per connection
+	SendQueueSize  int      `json:"send_queue_size"`
+	WriteTimeout   Duration `json:"write_timeout"`
+	MaxSendBacklog Duration `json:"max_send_backlog"`
+
+	// Heartbeats, a connection without a pong for pong_timeout is dropped.
+	// Players that send no input for idle_timeout are disconnected, zero never.
+	PingInterval Duration `json:"ping_interval"`
+	PongTimeout  Duration `json:"pong_timeout"`
+	IdleTimeout  Duration `json:"idle_timeout"`
+
+	// How long a disconnected player stays in the world waiting to resume
+	ReconnectGrace Duration `json:"reconnect_grace"`
+
+	// Address native clients connect to over raw TCP, empty disables it
+	TCPAddr string `json:"tcp_addr"`
+}
+
+// Room picks the mode and map a game runs with.
+// Mode, map, fog of war and capacity need a restart to change.
+type Room struct {
+	Mode       string `json:"mode"`
+	Map        string `json:"map"`
+	FogOfWar   bool   `json:"fog_of_war"`
+	MaxPlayers int    `json:"max_players"`
+}
+
+// Rules are the per-mode values, all of them are safe to change live.
+type Rules struct {
+	PlayerSpeed float32 `json:"player_speed"` // pixels per second
+	MaxHealth   int     `json:"max_health"`
+
+	MinPlayers    int      `json:"min_players"`
+	Countdown     Duration `json:"countdown"`
+	RoundDuration Duration `json:"round_duration"`
+	Overtime      Duration `json:"overtime"` // zero disables overtime
+	Intermission  Duration `json:"intermission"`
+
+	RespawnDelay Duration `json:"respawn_delay"`
+	AssistWindow Duration `json:"assist_window"`
+
+	TeamMaxImbalance   int      `json:"team_max_imbalance"`
+	TeamSwitchCooldown Duration `json:"team_switch_cooldown"`
+	TeamMaxSwitches    int      `json:"team_max_switches"` // 0 for unlimited
+}
+
+type Config struct {
+	Server Server           `json:"server"`
+	Rooms  map[string]Room  `json:"rooms"`
+	Modes  map[string]Rules `json:"modes"`
+}
+
+// Load reads the file at path, applies the environment overrides and validates the result.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var c Config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	if err := c.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Room returns the room called name together with the rules of its mode.
+func (c *Config) Room(name string) (Room, Rules, error) {
+	room, ok := c.Rooms[name]
+	if !ok {
+		return Room{}, Rules{}, fmt.Errorf("unknown room %q", name)
+	}
+	return room, c.Modes[room.Mode], nil
+}
+
+// applyEnv overrides file values with GAME_* environment variables.
+// Room overrides apply to every room.
+func (c *Config) applyEnv() error {
+	var errs []error
+
+	envFloat("GAME_FIXED_TPS", &c.Server.FixedTPS, &errs)
+	envInt("GAME_TARGET_FPS", &c.Server.TargetFPS, &errs)
+	envInt("GAME_MAX_SPECTATORS", &c.Server.MaxSpectators, &errs)
+	envDuration("GAME_SPECTATOR_DELAY", &c.Server.SpectatorDelay, &errs)
+	envInt("GAME_HISTORY_TICKS", &c.Server.HistoryTicks, &errs)
+	envDuration("GAME_MAX_REWIND", &c.Server.MaxRewind, &errs)
+	envBool("GAME_COMPACT_SNAPSHOTS", &c.Server.CompactSnapshots, &errs)
+	envDuration("GAME_FULL_STATE_INTERVAL", &c.Server.FullStateInterval, &errs)
+	envInt("GAME_SEND_QUEUE_SIZE", &c.Server.SendQueueSize, &errs)
+	envDuration("GAME_WRITE_TIMEOUT", &c.Server.WriteTimeout, &errs)
+	envDuration("GAME_MAX_SEND_BACKLOG", &c.Server.MaxSendBacklog, &errs)
+	envDuration("GAME_PING_INTERVAL", &c.Server.PingInterval, &errs)
+	envDuration("GAME_PONG_TIMEOUT", &c.Server.PongTimeout, &errs)
+	envDuration("GAME_IDLE_TIMEOUT", &c.Server.IdleTimeout, &errs)
+	envDuration("GAME_RECONNECT_GRACE", &c.Server.ReconnectGrace, &errs)
+	if addr, ok := os.LookupEnv("GAME_TCP_ADDR"); ok {
+		c.Server.TCPAddr = addr
+	}
+
+	var viewRadius float64
+	if envFloat("GAME_VIEW_RADIUS", &viewRadius, &errs) {
+		c.Server.ViewRadius = float32(viewRadius)
+	}
+
+	// Parse each variable once, then copy it into every room and mode
+	mode, hasMode := os.LookupEnv("GAME_MODE")
+	arena, hasMap := os.LookupEnv("GAME_MAP")
+	var maxPlayers, maxHealth, minPlayers int
+	var fog bool
+	var speed float64
+	var round Duration
+	hasMaxPlayers := envInt("GAME_MAX_PLAYERS", &maxPlayers, &errs)
+	hasFog := envBool("GAME_FOG_OF_WAR", &fog, &errs)
+	hasSpeed := envFloat("GAME_PLAYER_SPEED", &speed, &errs)
+	hasMaxHealth := envInt("GAME_MAX_HEALTH", &maxHealth, &errs)
+	hasMinPlayers := envInt("GAME_MIN_PLAYERS", &minPlayers, &errs)
+	hasRound := envDuration("GAME_ROUND_DURATION", &round, &errs)
+
+	for name, room := range c.Rooms {
+		if hasMode {
+			room.Mode = mode
+		}
+		if hasMap {
+			room.Map = arena
+		}
+		if hasMaxPlayers {
+			room.MaxPlayers = maxPlayers
+		}
+		if hasFog {
+			room.FogOfWar = fog
+		}
+		c.Rooms[name] = room
+	}
+
+	for name, rules := range c.Modes {
+		if hasSpeed {
+			rules.PlayerSpeed = float32(speed)
+		}
+		if hasMaxHealth {
+			rules.MaxHealth = maxHealth
+		}
+		if hasMinPlayers {
+			rules.MinPlayers = minPlayers
+		}
+		if hasRound {
+			rules.RoundDuration = round
+		}
+		c.Modes[name] = rules
+	}
+
+	return errors.Join(errs...)
+}
+
+// The env helpers report whether key was set to a valid value.
+
+func envInt(key string, dst *int, errs *[]error) bool {
+	if v, ok := os.LookupEnv(key); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			*errs = append(*errs, fmt.Errorf("%s: %w", key, err))
+			return false
+		}
+		*dst = n
+		return true
+	}
+	return false
+}
+
+func envFloat(key string, dst *float64, errs *[]error) bool {
+	if v, ok := os.LookupEnv(key); ok {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			*errs = append(*errs, fmt.Errorf("%s: %w", key, err))
+			return false
+		}
+		*dst = f
+		return true
+	}
+	return false
+}
+
+func envBool(key string, dst *bool, errs *[]error) bool {
+	if v, ok := os.LookupEnv(key); ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			*errs = append(*errs, fmt.Errorf("%s: %w", key, err))
+			return false
+		}
+		*dst = b
+		return true
+	}
+	return false
+}
+
+func envDuration(key string, dst *Duration, errs *[]error) bool {
+	if v, ok := os.LookupEnv(key); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			*errs = append(*errs, fmt.Errorf("%s: %w", key, err))
+			return false
+		}
+		dst.Duration = d
+		return true
+	}
+	return false
+}
+
+// Validate reports every invalid value at once.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.FixedTPS > 0 && c.Server.FixedTPS <= 240, "server.fixed_tps must be in (0, 240]")
+	check(c.Server.TargetFPS > 0, "server.target_fps must be positive")
+	check(c.Server.MaxSpectators >= 0, "server.max_spectators must not be negative")
+	check(c.Server.SpectatorDelay.Duration >= 0, "server.spectator_delay must not be negative")
+	check(c.Server.ViewRadius > 0, "server.view_radius must be positive")
+	check(c.Server.HistoryTicks > 0, "server.history_ticks must be positive")
+	check(c.Server.MaxRewind.Duration >= 0, "server.max_rewind must not be negative")
+	check(c.Server.MaxRewind.Seconds()*c.Server.FixedTPS < float64(c.Server.HistoryTicks), "server.max_rewind must fit in server.history_ticks")
+	check(c.Server.FullStateInterval.Duration >= 0, "server.full_state_interval must not be negative")
+	check(c.Server.SendQueueSize >= 2, "server.send_queue_size must be at least 2")
+	check(c.Server.WriteTimeout.Duration > 0, "server.write_timeout must be positive")
+	check(c.Server.MaxSendBacklog.Duration >= 0, "server.max_send_backlog must not be negative")
+	check(c.Server.PingInterval.Duration > 0, "server.ping_interval must be positive")
+	check(c.Server.PongTimeout.Duration > c.Server.PingInterval.Duration, "server.pong_timeout must be longer than server.ping_interval")
+	check(c.Server.IdleTimeout.Duration >= 0, "server.idle_timeout must not be negative")
+	check(c.Server.ReconnectGrace.Duration >= 0, "server.reconnect_grace must not be negative")
+	check(len(c.Rooms) > 0, "at least one room is required")
+
+	for name, room := range c.Rooms {
+		known := gamebase.HasMode(room.Mode)
+		_, hasRules := c.Modes[room.Mode]
+		check(known, "rooms.%s: unknown mode %q", name, room.Mode)
+		check(!known || hasRules, "rooms.%s: mode %q has no rules", name, room.Mode)
+		check(gamebase.HasArena(room.Map), "rooms.%s: unknown map %q", name, room.Map)
+		check(room.MaxPlayers > 0 && room.MaxPlayers <= 255, "rooms.%s: max_players must be in [1, 255]", name)
+	}
+
+	for name, rules := range c.Modes {
+		check(gamebase.HasMode(name), "modes.%s: unknown mode", name)
+		errs = append(errs, rules.validate("modes."+name)...)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (r Rules) validate(prefix string) []error {
+	var errs []error
+	check := func(ok bool, field string) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s.%s is out of range", prefix, field))
+		}
+	}
+
+	check(r.PlayerSpeed > 0, "player_speed")
+	check(r.MaxHealth > 0, "max_health")
+	check(r.MinPlayers >= 1, "min_players")
+	check(r.Countdown.Duration >= 0, "countdown")
+	check(r.RoundDuration.Duration > 0, "round_duration")
+	check(r.Overtime.Duration >= 0, "overtime")
+	check(r.Intermission.Duration >= 0, "intermission")
+	check(r.RespawnDelay.Duration >= 0, "respawn_delay")
+	check(r.AssistWindow.Duration >= 0, "assist_window")
+	check(r.TeamMaxImbalance >= 0, "team_max_imbalance")
+	check(r.TeamSwitchCooldown.Duration >= 0, "team_switch_cooldown")
+	check(r.TeamMaxSwitches >= 0, "team_max_switches")
+
+	return errs
+}
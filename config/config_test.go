@@ -0,0 +1,193 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func validConfig() *Config {
+	return &Config{
+		Server: Server{
+			FixedTPS:      30,
+			TargetFPS:     120,
+			MaxSpectators: 10,
+			ViewRadius:    1000,
+			HistoryTicks:  32,
+			MaxRewind:     Duration{250 * time.Millisecond},
+			SendQueueSize: 64,
+			WriteTimeout:  Duration{time.Second},
+			PingInterval:  Duration{5 * time.Second},
+			PongTimeout:   Duration{15 * time.Second},
+		},
+		Rooms: map[string]Room{
+			"default": {Mode: "ffa", Map: "default", MaxPlayers: 10},
+		},
+		Modes: map[string]Rules{
+			"ffa": {
+				PlayerSpeed:   800,
+				MaxHealth:     100,
+				MinPlayers:    2,
+				RoundDuration: Duration{5 * time.Minute},
+				RespawnDelay:  Duration{3 * time.Second},
+			},
+		},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(c *Config)
+		want   []string // substrings of the error, none for a valid config
+	}{
+		{"valid", func(c *Config) {}, nil},
+		{"fixed tps", func(c *Config) { c.Server.FixedTPS = 0 }, []string{"server.fixed_tps"}},
+		{"view radius", func(c *Config) { c.Server.ViewRadius = 0 }, []string{"server.view_radius"}},
+		{"max rewind beyond history", func(c *Config) { c.Server.MaxRewind = Duration{2 * time.Second} }, []string{"server.max_rewind"}},
+		{"send queue", func(c *Config) { c.Server.SendQueueSize = 1 }, []string{"server.send_queue_size"}},
+		{"pong timeout", func(c *Config) { c.Server.PongTimeout = c.Server.PingInterval }, []string{"server.pong_timeout"}},
+		{"no rooms", func(c *Config) { clear(c.Rooms) }, []string{"at least one room"}},
+		{"unknown room mode", func(c *Config) {
+			c.Rooms["default"] = Room{Mode: "dm", Map: "default", MaxPlayers: 10}
+		}, []string{`rooms.default: unknown mode "dm"`}},
+		{"room mode without rules", func(c *Config) {
+			c.Rooms["default"] = Room{Mode: "ctf", Map: "default", MaxPlayers: 10}
+		}, []string{`rooms.default: mode "ctf" has no rules`}},
+		{"unknown map", func(c *Config) {
+			c.Rooms["default"] = Room{Mode: "ffa", Map: "moon", MaxPlayers: 10}
+		}, []string{`rooms.default: unknown map "moon"`}},
+		{"max players", func(c *Config) {
+			c.Rooms["default"] = Room{Mode: "ffa", Map: "default", MaxPlayers: 256}
+		}, []string{"rooms.default: max_players"}},
+		{"unknown rules mode", func(c *Config) { c.Modes["dm"] = c.Modes["ffa"] }, []string{"modes.dm: unknown mode"}},
+		{"rules out of range", func(c *Config) {
+			rules := c.Modes["ffa"]
+			rules.RespawnDelay = Duration{-time.Second}
+			rules.MinPlayers = 0
+			c.Modes["ffa"] = rules
+		}, []string{"modes.ffa.respawn_delay", "modes.ffa.min_players"}},
+		{"every error at once", func(c *Config) {
+			c.Server.TargetFPS = 0
+			c.Rooms["default"] = Room{Mode: "ffa", Map: "moon", MaxPlayers: 10}
+		}, []string{"server.target_fps", "unknown map"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := validConfig()
+			tt.mutate(c)
+
+			err := c.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("no error, want %q", tt.want)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+		check   func(t *testing.T, c *Config)
+	}{
+		{"server values", map[string]string{"GAME_FIXED_TPS": "60", "GAME_VIEW_RADIUS": "500"}, "", func(t *testing.T, c *Config) {
+			if c.Server.FixedTPS != 60 || c.Server.ViewRadius != 500 {
+				t.Fatalf("server %+v", c.Server)
+			}
+		}},
+		{"room values apply to every room", map[string]string{"GAME_MODE": "ctf", "GAME_FOG_OF_WAR": "false"}, "", func(t *testing.T, c *Config) {
+			for name, room := range c.Rooms {
+				if room.Mode != "ctf" || room.FogOfWar {
+					t.Fatalf("room %s: %+v", name, room)
+				}
+			}
+		}},
+		{"rules apply to every mode", map[string]string{"GAME_ROUND_DURATION": "2m", "GAME_MAX_HEALTH": "150"}, "", func(t *testing.T, c *Config) {
+			for name, rules := range c.Modes {
+				if rules.RoundDuration.Duration != 2*time.Minute || rules.MaxHealth != 150 {
+					t.Fatalf("mode %s: %+v", name, rules)
+				}
+			}
+		}},
+		{"invalid value is reported and ignored", map[string]string{"GAME_MAX_PLAYERS": "ten"}, "GAME_MAX_PLAYERS", func(t *testing.T, c *Config) {
+			if got := c.Rooms["default"].MaxPlayers; got != 10 {
+				t.Fatalf("max players %d, want 10", got)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			c := validConfig()
+			c.Rooms["teams"] = Room{Mode: "ffa", Map: "open", FogOfWar: true, MaxPlayers: 10}
+
+			err := c.applyEnv()
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("error %v, want one mentioning %s", err, tt.wantErr)
+			}
+			tt.check(t, c)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{
+		"server": {
+			"fixed_tps": 30, "target_fps": 60, "max_spectators": 0, "spectator_delay": "1s", "view_radius": 800,
+			"history_ticks": 8, "max_rewind": "100ms", "send_queue_size": 16, "write_timeout": "1s",
+			"ping_interval": "5s", "pong_timeout": "15s"
+		},
+		"rooms": {"default": {"mode": "tdm", "map": "open", "max_players": 8}},
+		"modes": {"tdm": {"player_speed": 600, "max_health": 100, "min_players": 2, "round_duration": "5m"}}
+	}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GAME_MIN_PLAYERS", "4")
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	room, rules, err := c.Room("default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if room.Mode != "tdm" || rules.MinPlayers != 4 || c.Server.SpectatorDelay.Duration != time.Second {
+		t.Fatalf("loaded room %+v rules %+v server %+v", room, rules, c.Server)
+	}
+
+	if _, _, err := c.Room("missing"); err == nil {
+		t.Fatal("unknown room did not fail")
+	}
+}
+
+func TestLoadShippedConfig(t *testing.T) {
+	if _, err := Load("../config.json"); err != nil {
+		t.Fatal(err)
+	}
+}
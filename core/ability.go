@@ -0,0 +1,201 @@
+package core
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"sync"
+)
+
+var (
+	ErrNoAbility      = errors.New("no ability bound to slot")
+	ErrNoCharges      = errors.New("ability on cooldown")
+	ErrCasting        = errors.New("already casting")
+	ErrAbilityBlocked = errors.New("abilities are blocked")
+)
+
+// Ability is an action bound to an input slot. Every use spends a charge,
+// charges come back one at a time every Cooldown ticks and the ability
+// fires CastTime ticks after it was used.
+// Activate runs while the owning AbilitySet is locked and must not call back into it.
+type Ability interface {
+	AbilityID() uint16
+	Cooldown() int // ticks
+	MaxCharges() int
+	CastTime() int // ticks
+	Activate(obj GameObject)
+}
+
+type AbilityState struct {
+	Slot         int
+	AbilityID    uint16
+	Charges      int
+	MaxCharges   int
+	Cooldown     int // ticks per charge
+	CooldownLeft int // ticks until the next charge, 0 when full
+	CastLeft     int // ticks until a cast fires, 0 when not casting
+}
+
+type abilitySlot struct {
+	ability      Ability
+	charges      int
+	cooldownLeft int
+	castLeft     int
+	casting      bool
+}
+
+// AbilitySet holds the abilities bound to one object's input slots.
+// The zero value is ready to use.
+type AbilitySet struct {
+	mu    sync.Mutex
+	slots map[int]*abilitySlot
+	dirty bool
+}
+
+// Bind puts a at slot with all of its charges available.
+func (s *AbilitySet) Bind(slot int, a Ability) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.slots == nil {
+		s.slots = make(map[int]*abilitySlot)
+	}
+	s.slots[slot] = &abilitySlot{ability: a, charges: max(1, a.MaxCharges())}
+	s.dirty = true
+}
+
+// Use spends a charge of the ability at slot and starts casting it.
+// Abilities without a cast time fire immediately.
+func (s *AbilitySet) Use(obj GameObject, slot int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.slots[slot]
+	if !ok {
+		return ErrNoAbility
+	}
+	if a.casting {
+		return ErrCasting
+	}
+	if a.charges == 0 {
+		return ErrNoCharges
+	}
+
+	a.charges--
+	if a.cooldownLeft == 0 {
+		a.cooldownLeft = a.ability.Cooldown()
+	}
+	s.dirty = true
+
+	if a.ability.CastTime() <= 0 {
+		a.ability.Activate(obj)
+		return nil
+	}
+
+	a.casting = true
+	a.castLeft = a.ability.CastTime()
+	return nil
+}
+
+// Tick advances cooldowns and casts by one fixed update.
+func (s *AbilitySet) Tick(obj GameObject) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, a := range s.slots {
+		if a.casting {
+			a.castLeft--
+			if a.castLeft <= 0 {
+				a.casting = false
+				a.castLeft = 0
+				a.ability.Activate(obj)
+				s.dirty = true
+			}
+		}
+
+		if a.cooldownLeft > 0 {
+			a.cooldownLeft--
+			if a.cooldownLeft == 0 {
+				a.charges++
+				if a.charges < max(1, a.ability.MaxCharges()) {
+					a.cooldownLeft = a.ability.Cooldown()
+				}
+				s.dirty = true
+			}
+		}
+	}
+}
+
+// Interrupt cancels every cast in progress. The spent charges are not refunded.
+func (s *AbilitySet) Interrupt() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, a := range s.slots {
+		if a.casting {
+			a.casting = false
+			a.castLeft = 0
+			s.dirty = true
+		}
+	}
+}
+
+// Casting reports whether any ability is being cast.
+func (s *AbilitySet) Casting() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, a := range s.slots {
+		if a.casting {
+			return true
+		}
+	}
+	return false
+}
+
+// Reset refills every charge and cancels casts.
+func (s *AbilitySet) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, a := range s.slots {
+		a.charges = max(1, a.ability.MaxCharges())
+		a.cooldownLeft = 0
+		a.castLeft = 0
+		a.casting = false
+	}
+	s.dirty = true
+}
+
+func (s *AbilitySet) Snapshot() []AbilityState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]AbilityState, 0, len(s.slots))
+	for slot, a := range s.slots {
+		out = append(out, AbilityState{
+			Slot:         slot,
+			AbilityID:    a.ability.AbilityID(),
+			Charges:      a.charges,
+			MaxCharges:   max(1, a.ability.MaxCharges()),
+			Cooldown:     a.ability.Cooldown(),
+			CooldownLeft: a.cooldownLeft,
+			CastLeft:     a.castLeft,
+		})
+	}
+
+	slices.SortFunc(out, func(a, b AbilityState) int {
+		return cmp.Compare(a.Slot, b.Slot)
+	})
+	return out
+}
+
+// TakeDirty reports whether charges or casts changed since the last call and clears the flag.
+func (s *AbilitySet) TakeDirty() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dirty := s.dirty
+	s.dirty = false
+	return dirty
+}
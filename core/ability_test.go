@@ -0,0 +1,115 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+type testAbility struct {
+	cooldown   int
+	maxCharges int
+	castTime   int
+
+	activations int
+}
+
+func (a *testAbility) AbilityID() uint16       { return 1 }
+func (a *testAbility) Cooldown() int           { return a.cooldown }
+func (a *testAbility) MaxCharges() int         { return a.maxCharges }
+func (a *testAbility) CastTime() int           { return a.castTime }
+func (a *testAbility) Activate(obj GameObject) { a.activations++ }
+
+func TestAbilitySetChargesAndCooldown(t *testing.T) {
+	type step struct {
+		use  bool // false ticks the set
+		want error
+	}
+	use := func(want error) step { return step{use: true, want: want} }
+	tick := step{}
+
+	tests := []struct {
+		name        string
+		ability     testAbility
+		steps       []step
+		want        AbilityState
+		activations int
+	}{
+		{"single charge goes on cooldown", testAbility{cooldown: 3, maxCharges: 1},
+			[]step{use(nil), use(ErrNoCharges), tick},
+			AbilityState{Charges: 0, CooldownLeft: 2}, 1},
+		{"charge comes back after cooldown", testAbility{cooldown: 2, maxCharges: 1},
+			[]step{use(nil), tick, tick, use(nil)},
+			AbilityState{Charges: 0, CooldownLeft: 2}, 2},
+		{"charges refill one at a time", testAbility{cooldown: 2, maxCharges: 2},
+			[]step{use(nil), use(nil), use(ErrNoCharges), tick, tick},
+			AbilityState{Charges: 1, CooldownLeft: 2}, 2},
+		{"spending a charge keeps the running cooldown", testAbility{cooldown: 4, maxCharges: 2},
+			[]step{use(nil), tick, use(nil), tick},
+			AbilityState{Charges: 0, CooldownLeft: 2}, 2},
+		{"cooldown stops when full", testAbility{cooldown: 1, maxCharges: 2},
+			[]step{use(nil), tick, tick, tick},
+			AbilityState{Charges: 2, CooldownLeft: 0}, 1},
+		{"cast fires after cast time", testAbility{cooldown: 10, maxCharges: 1, castTime: 2},
+			[]step{use(nil), tick},
+			AbilityState{Charges: 0, CooldownLeft: 9, CastLeft: 1}, 0},
+		{"no use while casting", testAbility{cooldown: 10, maxCharges: 2, castTime: 2},
+			[]step{use(nil), use(ErrCasting), tick, tick, use(nil)},
+			AbilityState{Charges: 0, CooldownLeft: 8, CastLeft: 2}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var set AbilitySet
+			ability := tt.ability
+			set.Bind(0, &ability)
+
+			for i, s := range tt.steps {
+				if !s.use {
+					set.Tick(nil)
+					continue
+				}
+				if err := set.Use(nil, 0); !errors.Is(err, s.want) {
+					t.Fatalf("step %d: err %v, want %v", i, err, s.want)
+				}
+			}
+
+			got := set.Snapshot()[0]
+			if got.Charges != tt.want.Charges || got.CooldownLeft != tt.want.CooldownLeft || got.CastLeft != tt.want.CastLeft {
+				t.Fatalf("charges %d cooldown %d cast %d, want %d, %d and %d",
+					got.Charges, got.CooldownLeft, got.CastLeft, tt.want.Charges, tt.want.CooldownLeft, tt.want.CastLeft)
+			}
+			if ability.activations != tt.activations {
+				t.Fatalf("activated %d times, want %d", ability.activations, tt.activations)
+			}
+		})
+	}
+}
+
+func TestAbilitySetInterruptAndReset(t *testing.T) {
+	var set AbilitySet
+	ability := &testAbility{cooldown: 5, maxCharges: 2, castTime: 3}
+	set.Bind(0, ability)
+
+	if err := set.Use(nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	set.Interrupt()
+	for range 3 {
+		set.Tick(nil)
+	}
+	if ability.activations != 0 {
+		t.Fatal("interrupted cast fired")
+	}
+	if got := set.Snapshot()[0].Charges; got != 1 {
+		t.Fatalf("interrupt refunded the charge, %d charges", got)
+	}
+
+	if err := set.Use(nil, 1); !errors.Is(err, ErrNoAbility) {
+		t.Fatalf("empty slot err %v, want %v", err, ErrNoAbility)
+	}
+
+	set.Reset()
+	if got := set.Snapshot()[0]; got.Charges != 2 || got.CooldownLeft != 0 {
+		t.Fatalf("after reset %d charges and cooldown %d", got.Charges, got.CooldownLeft)
+	}
+}
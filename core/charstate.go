@@ -0,0 +1,111 @@
+package core
+
+import (
+	"errors"
+	"sync"
+)
+
+var ErrInvalidTransition = errors.New("invalid state transition")
+
+// CharacterState is what a character is doing, sent to clients to pick animations.
+type CharacterState uint8
+
+const (
+	StateIdle CharacterState = iota
+	StateMoving
+	StateAttacking
+	StateStunned
+	StateDead
+	StateRespawning
+)
+
+func (s CharacterState) String() string {
+	switch s {
+	case StateIdle:
+		return "idle"
+	case StateMoving:
+		return "moving"
+	case StateAttacking:
+		return "attacking"
+	case StateStunned:
+		return "stunned"
+	case StateDead:
+		return "dead"
+	case StateRespawning:
+		return "respawning"
+	default:
+		return "unknown"
+	}
+}
+
+// transitions lists the states each state may move to.
+// A dead character can only come back by respawning,
+// living characters respawn when a round restarts.
+var transitions = map[CharacterState][]CharacterState{
+	StateIdle:       {StateMoving, StateAttacking, StateStunned, StateDead, StateRespawning},
+	StateMoving:     {StateIdle, StateAttacking, StateStunned, StateDead, StateRespawning},
+	StateAttacking:  {StateIdle, StateMoving, StateStunned, StateDead, StateRespawning},
+	StateStunned:    {StateIdle, StateMoving, StateDead, StateRespawning},
+	StateDead:       {StateRespawning},
+	StateRespawning: {StateIdle, StateMoving, StateAttacking, StateStunned, StateDead},
+}
+
+func (s CharacterState) CanTransition(to CharacterState) bool {
+	for _, allowed := range transitions[s] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// AcceptsMovement reports whether movement input is valid in this state.
+func (s CharacterState) AcceptsMovement() bool {
+	return s != StateStunned && s != StateDead
+}
+
+// AcceptsAbility reports whether ability input is valid in this state.
+func (s CharacterState) AcceptsAbility() bool {
+	return s == StateIdle || s == StateMoving || s == StateRespawning
+}
+
+// StateMachine holds a character's current state.
+// The zero value starts idle and is ready to use.
+type StateMachine struct {
+	mu      sync.RWMutex
+	current CharacterState
+	changed bool
+}
+
+func (m *StateMachine) Current() CharacterState {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.current
+}
+
+// Transition moves to state to. Moving to the current state is a no-op.
+func (m *StateMachine) Transition(to CharacterState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.current == to {
+		return nil
+	}
+	if !m.current.CanTransition(to) {
+		return ErrInvalidTransition
+	}
+
+	m.current = to
+	m.changed = true
+	return nil
+}
+
+// TakeChanged reports whether the state changed since the last call and clears the flag.
+func (m *StateMachine) TakeChanged() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	changed := m.changed
+	m.changed = false
+	return changed
+}
@@ -29,6 +29,11 @@ func (c *Concrete) PositionXY() Point {
 }
 
 
+func (c *Concrete) SetPosition(pos Point) {
+	c.Position = pos
+}
+
+
 func (c *Concrete) Sprite(){
 
 }
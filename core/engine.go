@@ -3,6 +3,7 @@ package core
 import (
 	"math"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -15,6 +16,17 @@ type Engine struct {
 	fixedTickDelta float64
 	targetFPS int
 
+	tick atomic.Uint64
+
+	// Clock time is measured from epoch, it is monotonic
+	epoch   time.Time
+	clockMu sync.RWMutex
+	tickAt  time.Duration // when the current tick was simulated
+
+	// Past positions of every ConcreteObject, guarded by stateMu
+	history      map[int]*PositionHistory
+	historyDepth int
+
 	done chan struct{}
 	wg   sync.WaitGroup
 
@@ -32,8 +44,11 @@ func NewEngine(state *State,fixedTPS float64, targetFPS int) *Engine {
 		tickInterval:   tickInterval,
 		fixedTickDelta: tickInterval.Seconds(),
 		targetFPS:      targetFPS,
+		epoch:          time.Now(),
 		done:           make(chan struct{}),
 		eventQueue:     make(chan *Event, 1000),
+		history:        make(map[int]*PositionHistory),
+		historyDepth:   1,
 	}
 
 
@@ -74,8 +89,14 @@ func (e *Engine) runFixedUpdateLoop() {
 			for _, ent := range e.State.Entities {
 				ent.OnTick(e.fixedTickDelta)
 			}
+			e.recordHistory(e.tick.Load() + 1)
 			e.stateMu.Unlock()
 
+			e.clockMu.Lock()
+			e.tick.Add(1)
+			e.tickAt = e.Now()
+			e.clockMu.Unlock()
+
 			if e.OnFixedUpdate != nil {
 				e.OnFixedUpdate(e.fixedTickDelta)
 			}
@@ -88,6 +109,72 @@ func (e *Engine) runFixedUpdateLoop() {
 }
 
 
+// SetHistoryDepth sets how many ticks of positions are kept per object, call it before Run.
+func (e *Engine) SetHistoryDepth(ticks int) {
+	e.stateMu.Lock()
+	defer e.stateMu.Unlock()
+	e.historyDepth = max(1, ticks)
+	clear(e.history)
+}
+
+// recordHistory must be called with stateMu held.
+func (e *Engine) recordHistory(tick uint64) {
+	for id, conc := range e.State.ConcreteObjects {
+		h, ok := e.history[id]
+		if !ok {
+			h = NewPositionHistory(e.historyDepth)
+			e.history[id] = h
+		}
+		h.Record(tick, conc.PositionXY())
+	}
+}
+
+// PositionAt returns where object id was at the end of tick.
+func (e *Engine) PositionAt(id int, tick uint64) (Point, bool) {
+	e.stateMu.RLock()
+	defer e.stateMu.RUnlock()
+
+	h, ok := e.history[id]
+	if !ok {
+		return Point{}, false
+	}
+	return h.At(tick)
+}
+
+// Rewind calls fn with every object that has a recorded position at tick.
+// fn runs with the state read locked and must not modify objects.
+func (e *Engine) Rewind(tick uint64, fn func(obj GameObject, pos Point)) {
+	e.stateMu.RLock()
+	defer e.stateMu.RUnlock()
+
+	for id, h := range e.history {
+		obj, exists := e.State.Objects[id]
+		if !exists {
+			continue
+		}
+		if pos, ok := h.At(tick); ok {
+			fn(obj, pos)
+		}
+	}
+}
+
+// Tick returns the number of fixed updates simulated so far.
+func (e *Engine) Tick() uint64 {
+	return e.tick.Load()
+}
+
+// Now returns the time since the engine was created, it never goes backwards.
+func (e *Engine) Now() time.Duration {
+	return time.Since(e.epoch)
+}
+
+// LastTick returns the current tick along with the time Now returned when it was simulated.
+func (e *Engine) LastTick() (uint64, time.Duration) {
+	e.clockMu.RLock()
+	defer e.clockMu.RUnlock()
+	return e.tick.Load(), e.tickAt
+}
+
 func (e *Engine) runVariableUpdateLoop() {
 	targetFrameDuration := time.Second / time.Duration(e.targetFPS)
 
@@ -161,10 +248,12 @@ func (e *Engine) HandleEvent(ev *Event) {
 	}
 }
 
+// Shutdown stops the loops before closing the queue, the fixed update
+// may still be queueing events until it returns.
 func (e *Engine) Shutdown() {
 	close(e.done)
-	close(e.eventQueue)
 	e.wg.Wait()
+	close(e.eventQueue)
 }
 
 
@@ -198,12 +287,41 @@ func (e *Engine) RemoveObject(id int) {
 		delete(e.State.ConcreteObjects,id)
 
 		delete(e.State.PhysicsObjects,id)
+
+		delete(e.history, id)
 }
 
 
+// Objects returns a snapshot of every object in the world.
+func (e *Engine) Objects() []GameObject {
+	e.stateMu.RLock()
+	defer e.stateMu.RUnlock()
+
+	objects := make([]GameObject, 0, len(e.State.Objects))
+	for _, obj := range e.State.Objects {
+		objects = append(objects, obj)
+	}
+	return objects
+}
+
 func (e *Engine) GetObject(id int) GameObject {
     e.stateMu.RLock()
     obj := e.State.Objects[id]
     e.stateMu.RUnlock()
     return obj
 }
+
+// ReadState runs fn with the world state read locked. fn must not queue
+// events or take locks that are held around engine calls.
+func (e *Engine) ReadState(fn func()) {
+	e.stateMu.RLock()
+	defer e.stateMu.RUnlock()
+	fn()
+}
+
+// WriteState runs fn with the world state locked, under the same rules as ReadState.
+func (e *Engine) WriteState(fn func()) {
+	e.stateMu.Lock()
+	defer e.stateMu.Unlock()
+	fn()
+}
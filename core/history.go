@@ -0,0 +1,39 @@
+package core
+
+// PositionHistory is a ring buffer of an object's position at past ticks.
+type PositionHistory struct {
+	ticks     []uint64
+	positions []Point
+	next      int
+	count     int
+}
+
+func NewPositionHistory(depth int) *PositionHistory {
+	depth = max(1, depth)
+	return &PositionHistory{
+		ticks:     make([]uint64, depth),
+		positions: make([]Point, depth),
+	}
+}
+
+// Record stores pos as the position at tick, overwriting the oldest entry when full.
+func (h *PositionHistory) Record(tick uint64, pos Point) {
+	h.ticks[h.next] = tick
+	h.positions[h.next] = pos
+	h.next = (h.next + 1) % len(h.ticks)
+	h.count = min(h.count+1, len(h.ticks))
+}
+
+// At returns the position recorded at tick, if it is still in the buffer.
+func (h *PositionHistory) At(tick uint64) (Point, bool) {
+	for i := 0; i < h.count; i++ {
+		idx := (h.next - 1 - i + len(h.ticks)) % len(h.ticks)
+		if h.ticks[idx] == tick {
+			return h.positions[idx], true
+		}
+		if h.ticks[idx] < tick {
+			break
+		}
+	}
+	return Point{}, false
+}
@@ -1,7 +1,7 @@
 package core
 
 import (
-	"github.com/gorilla/websocket"
+	"game/transport"
 )
 
 type ObjectType uint8
@@ -10,6 +10,8 @@ const (
 	TypeObject ObjectType = iota
 	TypeConcreteObject
 	TypePlayer
+	TypeFlag
+	TypePickup
 )
 
 type Typed struct {
@@ -53,6 +55,7 @@ type ConcreteObject interface {
 	GameObject
 	Sprite()
 	PositionXY() Point
+	SetPosition(Point)
 }
 
 type PhysicsObject interface {
@@ -68,9 +71,8 @@ type PhysicsObject interface {
 
 type NetworkObject interface {
 	GameObject
-	Conn() *websocket.Conn
+	Transport() transport.Transport
 	CloseConn() error
-	SetConn(*websocket.Conn)
 }
 
 
@@ -1,5 +1,9 @@
 package core
 
+import (
+	"math"
+)
+
 type Point struct{
 	X,Y float32
 }
@@ -39,3 +43,23 @@ var (
 )
 
 
+
+
+func (v Vector) Length() float32 {
+	return float32(math.Hypot(float64(v.VX), float64(v.VY)))
+}
+
+// ClampLength scales v down so that its length is at most maxLen.
+func (v *Vector) ClampLength(maxLen float32) {
+	length := v.Length()
+	if length > maxLen && length > 0 {
+		v.Scale(maxLen / length)
+	}
+}
+
+// VectorFromAngle builds a vector from an angle in radians, 0 points right
+// and angles grow clockwise since Y points down.
+func VectorFromAngle(angle, magnitude float32) Vector {
+	sin, cos := math.Sincos(float64(angle))
+	return Vector{VX: float32(cos) * magnitude, VY: float32(sin) * magnitude}
+}
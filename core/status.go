@@ -0,0 +1,226 @@
+package core
+
+import (
+	"slices"
+	"sync"
+)
+
+type StackRule uint8
+
+const (
+	StackRefresh StackRule = iota // reapplying resets the duration
+	StackCount                    // reapplying adds a stack up to MaxStacks and resets the duration
+	StackUnique                   // reapplying while active does nothing
+)
+
+// StatusEffect is an effect that stays on an object for a number of ticks.
+// The hooks receive the object carrying the status and its current stack count.
+// They run while the owning StatusList is locked and must not call back into it.
+type StatusEffect interface {
+	StatusID() uint16
+	Tags() []string
+	Duration() int // ticks
+	StackRule() StackRule
+	MaxStacks() int
+	OnApply(obj GameObject, stacks int)
+	OnTick(obj GameObject, stacks int)
+	OnExpire(obj GameObject, stacks int)
+}
+
+// SpeedModifier is implemented by statuses that scale movement speed.
+type SpeedModifier interface {
+	SpeedFactor(stacks int) float32
+}
+
+// MovementBlocker is implemented by statuses that prevent movement.
+type MovementBlocker interface {
+	BlocksMovement() bool
+}
+
+// DamageBlocker is implemented by statuses that make their carrier invulnerable.
+type DamageBlocker interface {
+	BlocksDamage() bool
+}
+
+type StatusHolder interface {
+	GameObject
+	AddStatus(StatusEffect)
+	Dispel(tag string) int
+	ActiveStatuses() []ActiveStatus
+}
+
+type ActiveStatus struct {
+	Effect    StatusEffect
+	Stacks    int
+	Remaining int // ticks
+}
+
+// StatusList holds the statuses active on one object.
+// The zero value is ready to use.
+type StatusList struct {
+	mu     sync.Mutex
+	active []*ActiveStatus
+	dirty  bool
+}
+
+// Add applies s to obj following the status' stacking rule.
+func (l *StatusList) Add(obj GameObject, s StatusEffect) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, a := range l.active {
+		if a.Effect.StatusID() != s.StatusID() {
+			continue
+		}
+
+		switch s.StackRule() {
+		case StackUnique:
+			return
+		case StackCount:
+			if a.Stacks < max(1, s.MaxStacks()) {
+				a.Stacks++
+				s.OnApply(obj, a.Stacks)
+			}
+		}
+
+		a.Effect = s
+		a.Remaining = s.Duration()
+		l.dirty = true
+		return
+	}
+
+	a := &ActiveStatus{Effect: s, Stacks: 1, Remaining: s.Duration()}
+	l.active = append(l.active, a)
+	s.OnApply(obj, a.Stacks)
+	l.dirty = true
+}
+
+// Tick runs one fixed update for every status and expires the finished ones.
+func (l *StatusList) Tick(obj GameObject) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.active = slices.DeleteFunc(l.active, func(a *ActiveStatus) bool {
+		a.Effect.OnTick(obj, a.Stacks)
+		a.Remaining--
+		if a.Remaining > 0 {
+			return false
+		}
+
+		a.Effect.OnExpire(obj, a.Stacks)
+		l.dirty = true
+		return true
+	})
+}
+
+// Dispel removes every status tagged with tag and returns how many were removed.
+func (l *StatusList) Dispel(obj GameObject, tag string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	removed := 0
+	l.active = slices.DeleteFunc(l.active, func(a *ActiveStatus) bool {
+		if !slices.Contains(a.Effect.Tags(), tag) {
+			return false
+		}
+
+		a.Effect.OnExpire(obj, a.Stacks)
+		removed++
+		return true
+	})
+
+	if removed > 0 {
+		l.dirty = true
+	}
+	return removed
+}
+
+// Clear removes every status without running the expire hooks.
+func (l *StatusList) Clear() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.active) > 0 {
+		l.active = nil
+		l.dirty = true
+	}
+}
+
+func (l *StatusList) Snapshot() []ActiveStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	out := make([]ActiveStatus, len(l.active))
+	for i, a := range l.active {
+		out[i] = *a
+	}
+	return out
+}
+
+func (l *StatusList) SpeedFactor() float32 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	factor := float32(1)
+	for _, a := range l.active {
+		if mod, ok := a.Effect.(SpeedModifier); ok {
+			factor *= mod.SpeedFactor(a.Stacks)
+		}
+	}
+	return factor
+}
+
+func (l *StatusList) BlocksMovement() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, a := range l.active {
+		if blocker, ok := a.Effect.(MovementBlocker); ok && blocker.BlocksMovement() {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *StatusList) BlocksDamage() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, a := range l.active {
+		if blocker, ok := a.Effect.(DamageBlocker); ok && blocker.BlocksDamage() {
+			return true
+		}
+	}
+	return false
+}
+
+// TakeDirty reports whether the list changed since the last call and clears the flag.
+// Remaining ticks counting down do not count as a change.
+func (l *StatusList) TakeDirty() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	dirty := l.dirty
+	l.dirty = false
+	return dirty
+}
+
+type ApplyStatusEffect struct {
+	Status StatusEffect
+}
+
+func (e *ApplyStatusEffect) Apply(obj GameObject) {
+	if holder, IsHolder := obj.(StatusHolder); IsHolder {
+		holder.AddStatus(e.Status)
+	}
+}
+
+type DispelEffect struct {
+	Tag string
+}
+
+func (e *DispelEffect) Apply(obj GameObject) {
+	if holder, IsHolder := obj.(StatusHolder); IsHolder {
+		holder.Dispel(e.Tag)
+	}
+}
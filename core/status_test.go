@@ -0,0 +1,130 @@
+package core
+
+import (
+	"slices"
+	"testing"
+)
+
+type testStatus struct {
+	id        uint16
+	tags      []string
+	duration  int
+	rule      StackRule
+	maxStacks int
+
+	applied []int // stacks passed to OnApply
+	expired []int // stacks passed to OnExpire
+}
+
+func (s *testStatus) StatusID() uint16                   { return s.id }
+func (s *testStatus) Tags() []string                     { return s.tags }
+func (s *testStatus) Duration() int                      { return s.duration }
+func (s *testStatus) StackRule() StackRule               { return s.rule }
+func (s *testStatus) MaxStacks() int                     { return s.maxStacks }
+func (s *testStatus) OnApply(obj GameObject, stacks int) { s.applied = append(s.applied, stacks) }
+func (s *testStatus) OnTick(obj GameObject, stacks int)  {}
+func (s *testStatus) OnExpire(obj GameObject, stacks int) {
+	s.expired = append(s.expired, stacks)
+}
+
+func TestStatusListStacking(t *testing.T) {
+	const (
+		add = iota
+		tick
+	)
+	tests := []struct {
+		name          string
+		rule          StackRule
+		maxStacks     int
+		ops           []int
+		wantStacks    int // 0 when expired
+		wantRemaining int
+		wantApplied   []int
+		wantExpired   []int
+	}{
+		{"refresh resets duration", StackRefresh, 0, []int{add, tick, tick, add}, 1, 5, []int{1}, nil},
+		{"count stacks up to max", StackCount, 3, []int{add, add, add, add}, 3, 5, []int{1, 2, 3}, nil},
+		{"count without max holds one stack", StackCount, 0, []int{add, add}, 1, 5, []int{1}, nil},
+		{"count refreshes duration", StackCount, 3, []int{add, tick, add}, 2, 5, []int{1, 2}, nil},
+		{"unique ignores reapply", StackUnique, 0, []int{add, tick, add}, 1, 4, []int{1}, nil},
+		{"expires with its stacks", StackCount, 3, []int{add, add, tick, tick, tick, tick, tick}, 0, 0, []int{1, 2}, []int{2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var l StatusList
+			s := &testStatus{id: 1, duration: 5, rule: tt.rule, maxStacks: tt.maxStacks}
+
+			for _, op := range tt.ops {
+				if op == add {
+					l.Add(nil, s)
+				} else {
+					l.Tick(nil)
+				}
+			}
+
+			active := l.Snapshot()
+			switch {
+			case tt.wantStacks == 0 && len(active) != 0:
+				t.Fatalf("%d statuses active, want none", len(active))
+			case tt.wantStacks > 0 && len(active) != 1:
+				t.Fatalf("%d statuses active, want 1", len(active))
+			case tt.wantStacks > 0 && (active[0].Stacks != tt.wantStacks || active[0].Remaining != tt.wantRemaining):
+				t.Fatalf("stacks %d remaining %d, want %d and %d", active[0].Stacks, active[0].Remaining, tt.wantStacks, tt.wantRemaining)
+			}
+			if !slices.Equal(s.applied, tt.wantApplied) {
+				t.Fatalf("OnApply stacks %v, want %v", s.applied, tt.wantApplied)
+			}
+			if !slices.Equal(s.expired, tt.wantExpired) {
+				t.Fatalf("OnExpire stacks %v, want %v", s.expired, tt.wantExpired)
+			}
+		})
+	}
+}
+
+func TestStatusListDispel(t *testing.T) {
+	buff := &testStatus{id: 1, tags: []string{"buff"}, duration: 10}
+	slow := &testStatus{id: 2, tags: []string{"debuff"}, duration: 10}
+	stun := &testStatus{id: 3, tags: []string{"debuff", "crowd_control"}, duration: 10}
+
+	var l StatusList
+	for _, s := range []*testStatus{buff, slow, stun} {
+		l.Add(nil, s)
+	}
+	l.TakeDirty()
+
+	tests := []struct {
+		tag       string
+		removed   int
+		dirty     bool
+		remaining []uint16
+	}{
+		{"crowd_control", 1, true, []uint16{1, 2}},
+		{"crowd_control", 0, false, []uint16{1, 2}},
+		{"debuff", 1, true, []uint16{1}},
+		{"buff", 1, true, nil},
+	}
+
+	for _, tt := range tests {
+		if removed := l.Dispel(nil, tt.tag); removed != tt.removed {
+			t.Fatalf("dispel %q removed %d, want %d", tt.tag, removed, tt.removed)
+		}
+		if dirty := l.TakeDirty(); dirty != tt.dirty {
+			t.Fatalf("dispel %q dirty %v, want %v", tt.tag, dirty, tt.dirty)
+		}
+
+		var ids []uint16
+		for _, a := range l.Snapshot() {
+			ids = append(ids, a.Effect.StatusID())
+		}
+		if !slices.Equal(ids, tt.remaining) {
+			t.Fatalf("after dispel %q active %v, want %v", tt.tag, ids, tt.remaining)
+		}
+	}
+
+	for _, s := range []*testStatus{buff, slow, stun} {
+		if len(s.expired) != 1 {
+			t.Fatalf("status %d expired %d times, want once", s.id, len(s.expired))
+		}
+	}
+}
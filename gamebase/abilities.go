@@ -0,0 +1,151 @@
+package gamebase
+
+import (
+	"encoding/binary"
+	"time"
+
+	"game/core"
+	"game/player"
+	"game/protocol"
+)
+
+const (
+	AbilityDash uint16 = iota + 1
+)
+
+const (
+	SlotPrimary = iota
+	SlotSecondary
+)
+
+const (
+	dashCooldown = 3 * time.Second
+	dashCharges  = 2
+	dashDuration = 200 * time.Millisecond
+	dashFactor   = 3
+)
+
+// Dash bursts the character along its facing direction well above its
+// normal speed and makes it invulnerable for the length of the burst.
+type Dash struct {
+	cooldown int
+	charges  int
+	duration int
+	factor   float32
+}
+
+func NewDash(cooldownTicks, charges, durationTicks int, factor float32) *Dash {
+	return &Dash{
+		cooldown: cooldownTicks,
+		charges:  charges,
+		duration: durationTicks,
+		factor:   factor,
+	}
+}
+
+func (d *Dash) AbilityID() uint16 { return AbilityDash }
+func (d *Dash) Cooldown() int     { return d.cooldown }
+func (d *Dash) MaxCharges() int   { return d.charges }
+func (d *Dash) CastTime() int     { return 0 }
+
+func (d *Dash) Activate(obj core.GameObject) {
+	if holder, IsHolder := obj.(core.StatusHolder); IsHolder {
+		holder.AddStatus(NewDashStatus(d.factor, d.duration))
+	}
+}
+
+type DashStatus struct {
+	statusBase
+	factor float32
+}
+
+func NewDashStatus(factor float32, ticks int) *DashStatus {
+	return &DashStatus{
+		statusBase: statusBase{id: StatusDash, tags: []string{TagBuff}, duration: ticks, stackRule: core.StackRefresh},
+		factor:     factor,
+	}
+}
+
+func (s *DashStatus) SpeedFactor(stacks int) float32 {
+	return s.factor
+}
+
+func (s *DashStatus) BlocksDamage() bool {
+	return true
+}
+
+// OnApply launches the character along its facing direction at its
+// current speed, the speed factor then scales it up into the burst.
+func (s *DashStatus) OnApply(obj core.GameObject, stacks int) {
+	character, IsCharacter := obj.(Character)
+	physics, IsPhysics := obj.(core.PhysicsObject)
+	if !IsCharacter || !IsPhysics {
+		return
+	}
+
+	velocity := character.Facing()
+	velocity.Scale(character.GetSpeed())
+	physics.SetVelocity(&velocity)
+}
+
+type UseAbilityEffect struct {
+	Slot int
+}
+
+func (e *UseAbilityEffect) Apply(obj core.GameObject) {
+	if character, IsCharacter := obj.(Character); IsCharacter {
+		_ = character.UseAbility(e.Slot)
+	}
+}
+
+func (g *Game) bindDefaultAbilities(p *player.Player) {
+	p.BindAbility(SlotPrimary, NewDash(g.ticks(dashCooldown), dashCharges, g.ticks(dashDuration), dashFactor))
+}
+
+func (g *Game) HandleInputAbility(clientEv *core.ClientEvent, p *player.Player) {
+	if !g.Match.AcceptsMovement() || !p.State().AcceptsAbility() {
+		return
+	}
+
+	slot, ok := clientEv.Data["slot"].(float64)
+	if !ok || slot < 0 || slot > 255 || slot != float64(int(slot)) {
+		g.log.Println("Invalid slot in input_ability event from player", p.ID())
+		return
+	}
+
+	g.queueEffects(p.ID(), map[int][]core.IEffect{
+		p.ID(): {&UseAbilityEffect{Slot: int(slot)}},
+	})
+}
+
+func (g *Game) sendAbilityChanges() {
+	for _, p := range g.Players() {
+		if p.AbilitiesChanged() {
+			g.sendTo(p, g.abilityMessage(p))
+		}
+	}
+}
+
+// Payload: [1 byte count] then per slot
+// [1 byte slot][2 bytes ability id][1 byte charges][1 byte max charges]
+// [4 bytes cooldown ms][4 bytes cooldown left ms][4 bytes cast left ms]
+func (g *Game) abilityMessage(p *player.Player) []byte {
+	states := p.AbilityStates()
+
+	buf, offset := newMessage(protocol.MsgAbilityState, 1+17*len(states))
+	buf[offset] = byte(len(states))
+	offset++
+
+	for _, s := range states {
+		buf[offset] = byte(s.Slot)
+		binary.LittleEndian.PutUint16(buf[offset+1:offset+3], s.AbilityID)
+		buf[offset+3] = byte(s.Charges)
+		buf[offset+4] = byte(s.MaxCharges)
+		binary.LittleEndian.PutUint32(buf[offset+5:offset+9], uint32(g.Match.ticksToDuration(uint64(s.Cooldown)).Milliseconds()))
+		binary.LittleEndian.PutUint32(buf[offset+9:offset+13], uint32(g.Match.ticksToDuration(uint64(s.CooldownLeft)).Milliseconds()))
+		binary.LittleEndian.PutUint32(buf[offset+13:offset+17], uint32(g.Match.ticksToDuration(uint64(s.CastLeft)).Milliseconds()))
+		offset += 17
+	}
+
+	return buf
+}
@@ -1,5 +1,166 @@
 package gamebase
 
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"game/core"
+	"game/protocol"
+)
+
+// Arena is the static world grid. Solid cells are walls that block line of sight.
+// Cell (0, 0) spans world coordinates [0, CellSize) on both axes.
 type Arena struct {
+	CellSize float32
+	Width    int // cells
+	Height   int // cells
+
+	solid []bool
+}
+
+func NewArena(width, height int, cellSize float32) *Arena {
+	return &Arena{
+		CellSize: cellSize,
+		Width:    width,
+		Height:   height,
+		solid:    make([]bool, width*height),
+	}
+}
+
+// DefaultArena is a 1200x600 world with cover between the two CTF bases.
+func DefaultArena() *Arena {
+	a := NewArena(30, 15, 40)
+	a.FillRect(9, 2, 2, 4)
+	a.FillRect(9, 9, 2, 4)
+	a.FillRect(19, 2, 2, 4)
+	a.FillRect(19, 9, 2, 4)
+	a.FillRect(14, 6, 2, 3)
+	return a
+}
+
+// OpenArena is the same world as DefaultArena without any cover.
+func OpenArena() *Arena {
+	return NewArena(30, 15, 40)
+}
+
+// ArenaByName returns a fresh copy of one of the built in maps.
+func ArenaByName(name string) (*Arena, error) {
+	switch name {
+	case "default":
+		return DefaultArena(), nil
+	case "open":
+		return OpenArena(), nil
+	default:
+		return nil, fmt.Errorf("unknown map: %s", name)
+	}
+}
+
+// HasArena reports whether ArenaByName knows name.
+func HasArena(name string) bool {
+	_, err := ArenaByName(name)
+	return err == nil
+}
+
+func (a *Arena) inBounds(cx, cy int) bool {
+	return cx >= 0 && cy >= 0 && cx < a.Width && cy < a.Height
+}
+
+func (a *Arena) SetSolid(cx, cy int, solid bool) {
+	if a.inBounds(cx, cy) {
+		a.solid[cy*a.Width+cx] = solid
+	}
+}
+
+// FillRect makes a w by h block of cells solid starting at cell (cx, cy).
+func (a *Arena) FillRect(cx, cy, w, h int) {
+	for y := cy; y < cy+h; y++ {
+		for x := cx; x < cx+w; x++ {
+			a.SetSolid(x, y, true)
+		}
+	}
+}
+
+// IsSolid reports whether cell (cx, cy) is a wall. Cells outside the grid are open.
+func (a *Arena) IsSolid(cx, cy int) bool {
+	return a.inBounds(cx, cy) && a.solid[cy*a.Width+cx]
+}
+
+func (a *Arena) cellOf(p core.Point) (int, int) {
+	return int(math.Floor(float64(p.X / a.CellSize))), int(math.Floor(float64(p.Y / a.CellSize)))
+}
+
+func (a *Arena) IsSolidAt(p core.Point) bool {
+	return a.IsSolid(a.cellOf(p))
+}
+
+// LineOfSight reports whether the segment from -> to crosses no solid cell.
+// It walks the grid cells along the segment (Amanatides & Woo).
+func (a *Arena) LineOfSight(from, to core.Point) bool {
+	cx, cy := a.cellOf(from)
+	endX, endY := a.cellOf(to)
+
+	dx, dy := float64(to.X-from.X), float64(to.Y-from.Y)
+	size := float64(a.CellSize)
+
+	stepX, tMaxX, tDeltaX := gridStep(float64(from.X), dx, size, cx)
+	stepY, tMaxY, tDeltaY := gridStep(float64(from.Y), dy, size, cy)
+
+	for {
+		if a.IsSolid(cx, cy) {
+			return false
+		}
+		if cx == endX && cy == endY {
+			return true
+		}
+
+		if tMaxX < tMaxY {
+			if tMaxX > 1 {
+				return true
+			}
+			cx += stepX
+			tMaxX += tDeltaX
+		} else {
+			if tMaxY > 1 {
+				return true
+			}
+			cy += stepY
+			tMaxY += tDeltaY
+		}
+	}
+}
+
+// gridStep returns the traversal direction along one axis, the segment
+// parameter t at which the first cell boundary is crossed and the t
+// needed to cross a whole cell.
+func gridStep(start, delta, size float64, cell int) (int, float64, float64) {
+	switch {
+	case delta > 0:
+		return 1, (float64(cell+1)*size - start) / delta, size / delta
+	case delta < 0:
+		return -1, (float64(cell)*size - start) / delta, -size / delta
+	default:
+		return 0, math.Inf(1), math.Inf(1)
+	}
+}
+
+// Payload: [2 bytes width][2 bytes height][4 bytes cell size f32]
+// then one bit per cell, row major, least significant bit first
+func (g *Game) arenaMessage() []byte {
+	a := g.arena
+	cells := a.Width * a.Height
+
+	buf, offset := newMessage(protocol.MsgArena, 8+(cells+7)/8)
+	binary.LittleEndian.PutUint16(buf[offset:offset+2], uint16(a.Width))
+	binary.LittleEndian.PutUint16(buf[offset+2:offset+4], uint16(a.Height))
+	binary.LittleEndian.PutUint32(buf[offset+4:offset+8], math.Float32bits(a.CellSize))
+	offset += 8
+
+	for i, solid := range a.solid {
+		if solid {
+			buf[offset+i/8] |= 1 << (i % 8)
+		}
+	}
 
+	return buf
 }
@@ -0,0 +1,24 @@
+package gamebase
+
+import (
+	"encoding/binary"
+
+	"game/player"
+	"game/protocol"
+)
+
+func (g *Game) broadcastStateChanges() {
+	for _, p := range g.Players() {
+		if p.StateChanged() {
+			g.broadcastAbout(p, playerStateMessage(p))
+		}
+	}
+}
+
+// Payload: [4 bytes player][1 byte state]
+func playerStateMessage(p *player.Player) []byte {
+	buf, offset := newMessage(protocol.MsgPlayerState, 5)
+	binary.LittleEndian.PutUint32(buf[offset:offset+4], uint32(p.ID()))
+	buf[offset+4] = byte(p.State())
+	return buf
+}
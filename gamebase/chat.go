@@ -0,0 +1,55 @@
+package gamebase
+
+import (
+	"encoding/binary"
+	"strings"
+	"unicode/utf8"
+
+	"game/core"
+	"game/player"
+	"game/protocol"
+)
+
+const maxChatLength = 200 // runes
+
+func (g *Game) HandleChatMessage(clientEv *core.ClientEvent, p *player.Player) {
+	text, ok := clientEv.Data["text"].(string)
+	if !ok {
+		g.log.Println("Invalid text in chat_message event from player", p.ID())
+		return
+	}
+
+	text = strings.TrimSpace(strings.ToValidUTF8(text, ""))
+	if text == "" {
+		return
+	}
+	if utf8.RuneCountInString(text) > maxChatLength {
+		text = string([]rune(text)[:maxChatLength])
+	}
+
+	teamOnly, _ := clientEv.Data["team"].(bool)
+	team := g.Teams.TeamOf(p.ID())
+	if teamOnly && team == TeamNone {
+		g.log.Println("Team chat from free agent", p.ID())
+		return
+	}
+
+	// Payload: [4 bytes sender][1 byte team only][text]
+	buf, offset := newMessage(protocol.MsgChat, 5+len(text))
+	binary.LittleEndian.PutUint32(buf[offset:offset+4], uint32(p.ID()))
+	if teamOnly {
+		buf[offset+4] = 1
+	}
+	copy(buf[offset+5:], text)
+
+	if !teamOnly {
+		g.broadcast(buf)
+		return
+	}
+
+	for _, member := range g.Players() {
+		if g.Teams.TeamOf(member.ID()) == team {
+			g.sendTo(member, buf)
+		}
+	}
+}
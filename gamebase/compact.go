@@ -0,0 +1,170 @@
+package gamebase
+
+import (
+	"game/core"
+	"game/player"
+	"game/protocol"
+)
+
+// Compact snapshots carry positions quantized to the arena bounds and only
+// the fields of an object that changed since the client's baseline.
+const (
+	positionStep = 1.0 / 16 // pixels
+
+	typeBits  = 3
+	maskBits  = 4
+	teamBits  = 4 // TeamNone through MaxTeams
+	stateBits = 3
+	kindBits  = 4
+)
+
+// Field mask of a compact object record, one bit per field group.
+const (
+	fieldPosition uint64 = 1 << iota
+	fieldTeam
+	fieldState
+	fieldPickup
+)
+
+// compactFields is an object as a compact snapshot sees it.
+// It is comparable so unchanged objects can be skipped cheaply.
+type compactFields struct {
+	typ    core.ObjectType
+	x, y   uint64
+	team   uint8
+	state  uint8
+	kind   uint8
+	active bool
+}
+
+// typeFields returns the fields an object of type t carries.
+func typeFields(t core.ObjectType) uint64 {
+	switch t {
+	case core.TypePlayer:
+		return fieldPosition | fieldTeam | fieldState
+	case core.TypeFlag:
+		return fieldPosition | fieldTeam
+	case core.TypePickup:
+		return fieldPosition | fieldPickup
+	case core.TypeConcreteObject:
+		return fieldPosition
+	default:
+		return 0
+	}
+}
+
+// changedFields returns the fields that differ between old and cur.
+func changedFields(old, cur compactFields) uint64 {
+	var mask uint64
+	if old.x != cur.x || old.y != cur.y {
+		mask |= fieldPosition
+	}
+	if old.team != cur.team {
+		mask |= fieldTeam
+	}
+	if old.state != cur.state {
+		mask |= fieldState
+	}
+	if old.kind != cur.kind || old.active != cur.active {
+		mask |= fieldPickup
+	}
+	return mask & typeFields(cur.typ)
+}
+
+type compactCodec struct {
+	x, y protocol.Quantizer
+	w    *protocol.BitWriter // owned by the fixed update
+}
+
+// SetCompactSnapshots switches players to the quantized, bit packed snapshot
+// encoding. It needs the arena bounds, call it after SetArena and before Start.
+func (g *Game) SetCompactSnapshots(enabled bool) {
+	if !enabled || g.arena == nil {
+		g.compact = nil
+		return
+	}
+	g.compact = &compactCodec{
+		x: protocol.NewQuantizer(0, float32(g.arena.Width)*g.arena.CellSize, positionStep),
+		y: protocol.NewQuantizer(0, float32(g.arena.Height)*g.arena.CellSize, positionStep),
+		w: protocol.NewBitWriter(make([]byte, 0, 1024)),
+	}
+}
+
+// CompactQuantizers returns how compact snapshots quantize positions,
+// ok is false when snapshots use the full encoding.
+func (g *Game) CompactQuantizers() (x, y protocol.Quantizer, ok bool) {
+	if g.compact == nil {
+		return protocol.Quantizer{}, protocol.Quantizer{}, false
+	}
+	return g.compact.x, g.compact.y, true
+}
+
+func (c *compactCodec) fieldsOf(obj core.GameObject) compactFields {
+	var f compactFields
+	if typed, ok := obj.(interface{ GetType() core.ObjectType }); ok {
+		f.typ = typed.GetType()
+	}
+	if conc, ok := obj.(core.ConcreteObject); ok {
+		pos := conc.PositionXY()
+		f.x, f.y = c.x.Quantize(pos.X), c.y.Quantize(pos.Y)
+	}
+
+	switch o := obj.(type) {
+	case *player.Player:
+		f.team = o.Team()
+		f.state = uint8(o.State())
+	case *Flag:
+		f.team = o.Team()
+	case *Pickup:
+		f.kind = uint8(o.Kind())
+		f.active = o.Active()
+	}
+	return f
+}
+
+// Payload, bit packed least significant bit first:
+// [1 bit keyframe][varint left count][varint id per left object]
+// [varint despawned count][varint id per despawned object]
+// [varint changed count] then per changed object
+// [varint id][3 bits type][4 bits field mask][fields in mask order]
+// Fields: position [x bits][y bits], team [4 bits], state [3 bits],
+// pickup [4 bits kind][1 bit active]. Quantizer bits come with the welcome.
+func (c *compactCodec) snapshotMessage(keyframe bool, left, despawned []int, changed []snapshotChange) []byte {
+	w := c.w
+	w.Reset()
+
+	w.WriteBool(keyframe)
+	for _, ids := range [][]int{left, despawned} {
+		w.WriteVarint(uint64(len(ids)))
+		for _, id := range ids {
+			w.WriteVarint(uint64(id))
+		}
+	}
+
+	w.WriteVarint(uint64(len(changed)))
+	for _, ch := range changed {
+		f := ch.record.fields
+		w.WriteVarint(uint64(ch.id))
+		w.WriteBits(uint64(f.typ), typeBits)
+		w.WriteBits(ch.mask, maskBits)
+
+		if ch.mask&fieldPosition != 0 {
+			w.WriteBits(f.x, c.x.Bits)
+			w.WriteBits(f.y, c.y.Bits)
+		}
+		if ch.mask&fieldTeam != 0 {
+			w.WriteBits(uint64(f.team), teamBits)
+		}
+		if ch.mask&fieldState != 0 {
+			w.WriteBits(uint64(f.state), stateBits)
+		}
+		if ch.mask&fieldPickup != 0 {
+			w.WriteBits(uint64(f.kind), kindBits)
+			w.WriteBool(f.active)
+		}
+	}
+
+	buf, offset := newMessage(protocol.MsgCompactSnapshot, w.Len())
+	copy(buf[offset:], w.Bytes())
+	return buf
+}
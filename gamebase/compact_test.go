@@ -0,0 +1,250 @@
+package gamebase
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"testing"
+
+	"game/core"
+	"game/player"
+	"game/protocol"
+)
+
+func TestCompactTeamBitsHoldEveryTeam(t *testing.T) {
+	if MaxTeams >= 1<<teamBits {
+		t.Fatalf("MaxTeams %d does not fit in %d team bits", MaxTeams, teamBits)
+	}
+}
+
+// compactRecord is one object as decoded from a compact snapshot.
+type compactRecord struct {
+	id     int
+	typ    core.ObjectType
+	mask   uint64
+	x, y   float32
+	team   uint8
+	state  uint8
+	kind   uint8
+	active bool
+}
+
+// decodeCompactSnapshot reads a compact snapshot the way clients do.
+func decodeCompactSnapshot(t *testing.T, c *compactCodec, buf []byte) (bool, []compactRecord) {
+	t.Helper()
+
+	h, err := protocol.ParseHeader(buf)
+	if err != nil || h.Type != protocol.MsgCompactSnapshot {
+		t.Fatalf("header %+v, %v", h, err)
+	}
+	r := protocol.NewBitReader(buf[protocol.HeaderSize:])
+	must := func(v uint64, err error) uint64 {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	keyframe := must(r.ReadBits(1)) == 1
+	for range 2 { // left, then despawned
+		for range must(r.ReadVarint()) {
+			must(r.ReadVarint())
+		}
+	}
+
+	var records []compactRecord
+	for range must(r.ReadVarint()) {
+		rec := compactRecord{
+			id:   int(must(r.ReadVarint())),
+			typ:  core.ObjectType(must(r.ReadBits(typeBits))),
+			mask: must(r.ReadBits(maskBits)),
+		}
+		if rec.mask&fieldPosition != 0 {
+			rec.x = c.x.Dequantize(must(r.ReadBits(c.x.Bits)))
+			rec.y = c.y.Dequantize(must(r.ReadBits(c.y.Bits)))
+		}
+		if rec.mask&fieldTeam != 0 {
+			rec.team = uint8(must(r.ReadBits(teamBits)))
+		}
+		if rec.mask&fieldState != 0 {
+			rec.state = uint8(must(r.ReadBits(stateBits)))
+		}
+		if rec.mask&fieldPickup != 0 {
+			rec.kind = uint8(must(r.ReadBits(kindBits)))
+			rec.active = must(r.ReadBits(1)) == 1
+		}
+		records = append(records, rec)
+	}
+	return keyframe, records
+}
+
+func TestCompactSnapshotRoundTrip(t *testing.T) {
+	g := benchGame(t, true)
+	l := log.New(io.Discard, "", 0)
+
+	p := player.NewPlayer(3, "user3", 123.456, 0.03, 200, l)
+	p.SetTeam(2)
+	edge := player.NewPlayer(300, "user300", 1199.99, 599.97, 200, l)
+	pickup := NewPickup(1000, PickupSpeed, core.Point{X: 640.5, Y: 321.25})
+	objects := []core.GameObject{p, edge, pickup}
+
+	within := func(got, want float32) bool {
+		return math.Abs(float64(got-want)) <= positionStep
+	}
+	check := func(rec compactRecord, obj core.GameObject) {
+		t.Helper()
+		pos := obj.(core.ConcreteObject).PositionXY()
+		if rec.mask&fieldPosition != 0 && (!within(rec.x, pos.X) || !within(rec.y, pos.Y)) {
+			t.Fatalf("object %d at (%v, %v), want (%v, %v)", rec.id, rec.x, rec.y, pos.X, pos.Y)
+		}
+	}
+
+	keyframe, records := decodeCompactSnapshot(t, g.compact, encodeSnapshot(g, true, nil, g.snapshotObjects(objects)))
+	if !keyframe || len(records) != len(objects) {
+		t.Fatalf("keyframe %v with %d records, want %d", keyframe, len(records), len(objects))
+	}
+	byID := map[int]core.GameObject{p.ID(): p, edge.ID(): edge, pickup.ID(): pickup}
+	for _, rec := range records {
+		obj := byID[rec.id]
+		if obj == nil {
+			t.Fatalf("unknown object %d", rec.id)
+		}
+		if rec.mask != typeFields(rec.typ) {
+			t.Fatalf("keyframe object %d has fields %b, want %b", rec.id, rec.mask, typeFields(rec.typ))
+		}
+		check(rec, obj)
+		switch rec.id {
+		case p.ID():
+			if rec.typ != core.TypePlayer || rec.team != 2 || rec.state != uint8(p.State()) {
+				t.Fatalf("player decoded as %+v", rec)
+			}
+		case pickup.ID():
+			if rec.typ != core.TypePickup || rec.kind != uint8(PickupSpeed) || !rec.active {
+				t.Fatalf("pickup decoded as %+v", rec)
+			}
+		}
+	}
+
+	// A delta carries only what changed
+	held := []snapshotFrame{g.snapshotObjects(objects)}
+	p.SetPosition(core.Point{X: 124.2, Y: 10})
+	_, records = decodeCompactSnapshot(t, g.compact, encodeSnapshot(g, false, held, g.snapshotObjects(objects)))
+	if len(records) != 1 || records[0].id != p.ID() || records[0].mask != fieldPosition {
+		t.Fatalf("delta decoded as %+v, want the player's position only", records)
+	}
+	check(records[0], p)
+}
+
+// benchObjects returns players spread over the default arena and a few pickups.
+func benchObjects(players int) ([]core.GameObject, []*player.Player) {
+	l := log.New(io.Discard, "", 0)
+
+	var objects []core.GameObject
+	var moving []*player.Player
+	for i := 0; i < players; i++ {
+		p := player.NewPlayer(i, fmt.Sprint("user", i), float32(40+i*37%1100), float32(40+i*53%500), 200, l)
+		objects = append(objects, p)
+		moving = append(moving, p)
+	}
+	for i, cfg := range DefaultPickups() {
+		objects = append(objects, NewPickup(1000+i, cfg.Kind, cfg.Position))
+	}
+	return objects, moving
+}
+
+func benchGame(tb testing.TB, compact bool) *Game {
+	arena, err := ArenaByName("default")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	g := &Game{}
+	g.SetArena(arena, false)
+	g.SetCompactSnapshots(compact)
+	return g
+}
+
+// encodeSnapshot builds the snapshot message interestUpdates would send.
+func encodeSnapshot(g *Game, keyframe bool, held []snapshotFrame, current snapshotFrame) []byte {
+	var changed []snapshotChange
+	for id, record := range current {
+		mask, ok := typeFields(record.fields.typ), true
+		if !keyframe {
+			mask, ok = g.changeSince(held, id, record)
+		}
+		if ok {
+			changed = append(changed, snapshotChange{id: id, record: record, mask: mask})
+		}
+	}
+	if g.compact != nil {
+		return g.compact.snapshotMessage(keyframe, nil, nil, changed)
+	}
+	return snapshotMessage(keyframe, nil, nil, changed)
+}
+
+// legacyPositionUpdate builds the position_update message the server sent
+// before snapshots: [4 bytes type length]["position_update"] then
+// [4 bytes id][1 byte type][4 bytes X f32][4 bytes Y f32] per moved object.
+func legacyPositionUpdate(objects []core.GameObject, prev map[int]core.Point) []byte {
+	const typ = "position_update"
+	buf := binary.LittleEndian.AppendUint32(nil, uint32(len(typ)))
+	buf = append(buf, typ...)
+
+	for _, obj := range objects {
+		conc := obj.(core.ConcreteObject)
+		pos := conc.PositionXY()
+		if last, ok := prev[obj.ID()]; ok && last == pos {
+			continue
+		}
+		prev[obj.ID()] = pos
+
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(obj.ID()))
+		buf = append(buf, byte(conc.(interface{ GetType() core.ObjectType }).GetType()))
+		buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(pos.X))
+		buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(pos.Y))
+	}
+	return buf
+}
+
+// benchmarkSnapshots encodes one tick of the same scene, every player moving,
+// and reports its size. encoding is "position_update" for the message sent
+// before snapshots, "full" or "compact" for snapshot deltas against the previous tick.
+func benchmarkSnapshots(b *testing.B, encoding string) {
+	for _, n := range []int{10, 50} {
+		b.Run(fmt.Sprint(n, "players"), func(b *testing.B) {
+			g := benchGame(b, encoding == "compact")
+			objects, moving := benchObjects(n)
+			prev := g.snapshotObjects(objects)
+			legacy := make(map[int]core.Point)
+			legacyPositionUpdate(objects, legacy)
+
+			total := 0
+			for i := 0; i < b.N; i++ {
+				// Back and forth so nobody ends up clamped at the arena edge
+				step := float32(3.3)
+				if i%2 == 1 {
+					step = -step
+				}
+				for _, p := range moving {
+					pos := p.PositionXY()
+					p.SetPosition(core.Point{X: pos.X + step, Y: pos.Y + step/2})
+				}
+
+				if encoding == "position_update" {
+					total += len(legacyPositionUpdate(objects, legacy))
+					continue
+				}
+				current := g.snapshotObjects(objects)
+				total += len(encodeSnapshot(g, false, []snapshotFrame{prev}, current))
+				prev = current
+			}
+			b.ReportMetric(float64(total)/float64(b.N), "bytes/tick")
+		})
+	}
+}
+
+func BenchmarkPositionUpdate(b *testing.B)       { benchmarkSnapshots(b, "position_update") }
+func BenchmarkSnapshotDelta(b *testing.B)        { benchmarkSnapshots(b, "full") }
+func BenchmarkCompactSnapshotDelta(b *testing.B) { benchmarkSnapshots(b, "compact") }
@@ -0,0 +1,286 @@
+package gamebase
+
+import (
+	"encoding/binary"
+	"time"
+
+	"game/core"
+	"game/protocol"
+)
+
+type Flag struct {
+	core.Concrete
+	team TeamID
+}
+
+func NewFlag(id int, team TeamID, pos core.Point) *Flag {
+	f := &Flag{
+		Concrete: *core.NewConcreteObject(id, nil, pos),
+		team:     team,
+	}
+	f.SetType(core.TypeFlag)
+	return f
+}
+
+func (f *Flag) Team() TeamID {
+	return f.team
+}
+
+//Serializable
+// Flag layout: [Concrete][1 byte team]
+
+func (f *Flag) ToBytes(buf []byte, start int) int {
+	n := f.Concrete.ToBytes(buf, start)
+	buf[start+n] = f.team
+	return n + 1
+}
+
+func (f *Flag) ToDeltaBytes(buf []byte, start int) int {
+	n := f.Concrete.ToDeltaBytes(buf, start)
+	if n == 0 {
+		return 0
+	}
+	buf[start+n] = f.team
+	return n + 1
+}
+
+func (f *Flag) Size() int {
+	return f.Concrete.Size() + 1
+}
+
+func (f *Flag) DeltaSize() int {
+	return f.Concrete.DeltaSize() + 1
+}
+
+type flagState uint8
+
+const (
+	flagHome flagState = iota
+	flagCarried
+	flagDropped
+)
+
+type FlagEvent uint8
+
+const (
+	FlagPickedUp FlagEvent = iota
+	FlagDropped
+	FlagCaptured
+	FlagReturned
+)
+
+type CTFConfig struct {
+	Bases         []core.Point // one per team, index 0 is team 1
+	PickupRadius  float32
+	CaptureRadius float32
+	ReturnAfter   time.Duration // dropped flags go home after this long
+	CapturePoints int
+	ReturnPoints  int
+}
+
+func DefaultCTFConfig() CTFConfig {
+	return CTFConfig{
+		Bases:         []core.Point{{X: 100, Y: 300}, {X: 1100, Y: 300}},
+		PickupRadius:  40,
+		CaptureRadius: 60,
+		ReturnAfter:   15 * time.Second,
+		CapturePoints: 100,
+		ReturnPoints:  10,
+	}
+}
+
+type ctfFlag struct {
+	flag      *Flag
+	home      core.Point
+	state     flagState
+	carrierID int
+	droppedAt uint64
+}
+
+// CaptureTheFlag gives every team a flag at its base. A flag is carried
+// as a child object of its carrier and scores when brought home while
+// the carrier's own flag is at its base.
+type CaptureTheFlag struct {
+	baseMode
+	config CTFConfig
+	flags  []*ctfFlag // index 0 is team 1
+
+	returnAfterTicks uint64
+}
+
+func NewCaptureTheFlag(config CTFConfig) *CaptureTheFlag {
+	return &CaptureTheFlag{config: config}
+}
+
+func (m *CaptureTheFlag) Name() string   { return "ctf" }
+func (m *CaptureTheFlag) TeamCount() int { return min(len(m.config.Bases), MaxTeams) }
+
+func (m *CaptureTheFlag) SpawnPoint(team TeamID) core.Point {
+	if team == TeamNone || int(team) > m.TeamCount() {
+		return core.Point{X: 0, Y: 0}
+	}
+	return m.config.Bases[team-1]
+}
+
+func (m *CaptureTheFlag) Setup(g *Game) {
+	m.returnAfterTicks = g.Match.durationToTicks(m.config.ReturnAfter)
+
+	for i, base := range m.config.Bases[:m.TeamCount()] {
+		flag := NewFlag(g.NewObjectID(), TeamID(i+1), base)
+		g.Engine.AddObject(flag)
+		m.flags = append(m.flags, &ctfFlag{flag: flag, home: base, state: flagHome, carrierID: -1})
+	}
+}
+
+func (m *CaptureTheFlag) OnRoundStart(g *Game) {
+	for _, f := range m.flags {
+		if f.state != flagHome {
+			m.returnFlag(g, f, -1)
+		}
+	}
+}
+
+// ctfPlayer is what a tick needs to know about a player, copied under the
+// state lock so the rules below can queue events and broadcast freely.
+type ctfPlayer struct {
+	id    int
+	team  TeamID
+	alive bool
+	pos   core.Point
+}
+
+func (m *CaptureTheFlag) OnTick(g *Game) {
+	players := g.Players()
+	tick := g.Engine.Tick()
+
+	seen := make(map[int]ctfPlayer, len(players))
+	flagPos := make([]core.Point, len(m.flags))
+	g.Engine.ReadState(func() {
+		for _, p := range players {
+			seen[p.ID()] = ctfPlayer{id: p.ID(), team: p.Team(), alive: p.IsAlive(), pos: p.PositionXY()}
+		}
+		for i, f := range m.flags {
+			flagPos[i] = f.flag.PositionXY()
+		}
+	})
+
+	for i, f := range m.flags {
+		switch f.state {
+		case flagCarried:
+			carrier, ok := seen[f.carrierID]
+			if !ok || !carrier.alive || carrier.team == f.flag.Team() {
+				m.dropFlag(g, f, tick, flagPos[i])
+				continue
+			}
+
+			ownFlag := m.flagOf(carrier.team)
+			if ownFlag != nil && ownFlag.state == flagHome &&
+				withinRadius(carrier.pos, m.SpawnPoint(carrier.team), m.config.CaptureRadius) {
+				m.captureFlag(g, f, carrier.team)
+			}
+
+		case flagHome, flagDropped:
+			if f.state == flagDropped && tick-f.droppedAt >= m.returnAfterTicks {
+				m.returnFlag(g, f, -1)
+				continue
+			}
+
+			for _, p := range seen {
+				if !p.alive || p.team == TeamNone ||
+					!withinRadius(p.pos, flagPos[i], m.config.PickupRadius) {
+					continue
+				}
+
+				if p.team != f.flag.Team() {
+					m.pickupFlag(g, f, p.id)
+					break
+				}
+				if f.state == flagDropped {
+					m.returnFlag(g, f, p.id)
+					break
+				}
+			}
+		}
+	}
+}
+
+func (m *CaptureTheFlag) flagOf(team TeamID) *ctfFlag {
+	if team == TeamNone || int(team) > len(m.flags) {
+		return nil
+	}
+	return m.flags[team-1]
+}
+
+func (m *CaptureTheFlag) pickupFlag(g *Game, f *ctfFlag, playerID int) {
+	f.state = flagCarried
+	f.carrierID = playerID
+
+	g.queueEffects(playerID, map[int][]core.IEffect{
+		playerID: {&AttachEffect{Child: f.flag}},
+	})
+	g.broadcastFlagEvent(FlagPickedUp, f.flag.Team(), playerID)
+}
+
+func (m *CaptureTheFlag) dropFlag(g *Game, f *ctfFlag, tick uint64, at core.Point) {
+	carrierID := f.carrierID
+	f.state = flagDropped
+	f.carrierID = -1
+	f.droppedAt = tick
+
+	g.queueEffects(carrierID, map[int][]core.IEffect{
+		carrierID:   {&DetachEffect{ChildID: f.flag.ID()}},
+		f.flag.ID(): {&PlaceEffect{Position: at}},
+	})
+	g.broadcastFlagEvent(FlagDropped, f.flag.Team(), carrierID)
+}
+
+func (m *CaptureTheFlag) captureFlag(g *Game, f *ctfFlag, team TeamID) {
+	carrierID := f.carrierID
+
+	g.addTeamScore(team, 1)
+	g.Scoreboard.AddObjective(carrierID, m.config.CapturePoints)
+
+	f.state = flagHome
+	f.carrierID = -1
+
+	g.queueEffects(carrierID, map[int][]core.IEffect{
+		carrierID:   {&DetachEffect{ChildID: f.flag.ID()}},
+		f.flag.ID(): {&PlaceEffect{Position: f.home}},
+	})
+	g.broadcastFlagEvent(FlagCaptured, f.flag.Team(), carrierID)
+}
+
+// returnFlag sends a flag home, playerID is -1 when no player returned it.
+func (m *CaptureTheFlag) returnFlag(g *Game, f *ctfFlag, playerID int) {
+	effects := map[int][]core.IEffect{
+		f.flag.ID(): {&PlaceEffect{Position: f.home}},
+	}
+	if f.state == flagCarried {
+		effects[f.carrierID] = []core.IEffect{&DetachEffect{ChildID: f.flag.ID()}}
+	}
+
+	f.state = flagHome
+	f.carrierID = -1
+
+	if playerID >= 0 {
+		g.Scoreboard.AddObjective(playerID, m.config.ReturnPoints)
+	}
+
+	g.queueEffects(playerID, effects)
+	g.broadcastFlagEvent(FlagReturned, f.flag.Team(), playerID)
+}
+
+func withinRadius(a, b core.Point, radius float32) bool {
+	dx, dy := a.X-b.X, a.Y-b.Y
+	return dx*dx+dy*dy <= radius*radius
+}
+
+// Payload: [1 byte event][1 byte flag team][4 bytes player, -1 for none]
+func (g *Game) broadcastFlagEvent(event FlagEvent, team TeamID, playerID int) {
+	buf, offset := newMessage(protocol.MsgFlagEvent, 6)
+	buf[offset] = byte(event)
+	buf[offset+1] = team
+	binary.LittleEndian.PutUint32(buf[offset+2:offset+6], uint32(int32(playerID)))
+
+	g.broadcast(buf)
+}
@@ -0,0 +1,76 @@
+package gamebase
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"game/core"
+	"game/player"
+)
+
+func newTestGame(t *testing.T) *Game {
+	t.Helper()
+
+	state := &State{
+		Base: &core.State{
+			Objects:         make(map[int]core.GameObject),
+			ConcreteObjects: make(map[int]core.ConcreteObject),
+			Entities:        make(map[int]core.Entity),
+			PhysicsObjects:  make(map[int]core.PhysicsObject),
+		},
+		Players: make(map[string]*player.Player),
+	}
+	return NewGame(state, 200, 60, 8, MatchConfig{}, log.New(io.Discard, "", 0))
+}
+
+func addTestPlayer(t *testing.T, g *Game, id int) *player.Player {
+	t.Helper()
+
+	p := player.NewPlayer(id, fmt.Sprint("user", id), 0, 0, 200, g.log)
+	p.SetProfile(player.Profile{Name: fmt.Sprint("Player", id)})
+	if err := g.AddPlayer(p); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// Run with -race: the flag rules and team chat read player state while the
+// engine applies effects to it.
+func TestCaptureTheFlagConcurrentEffects(t *testing.T) {
+	g := newTestGame(t)
+	ctf := NewCaptureTheFlag(DefaultCTFConfig())
+	g.SetMode(ctf, TeamRules{MaxImbalance: 4})
+
+	var players []*player.Player
+	for id := range 4 {
+		players = append(players, addTestPlayer(t, g, id))
+	}
+
+	var sent atomic.Int32
+	g.SendFunc = func(*player.Player, []byte) { sent.Add(1) }
+	g.Engine.OnFixedUpdate = func(float64) { ctf.OnTick(g) }
+	g.Start()
+
+	chat := &core.ClientEvent{Type: "chat_message", Data: map[string]any{"text": "go", "team": true}}
+	deadline := time.Now().Add(100 * time.Millisecond)
+	for i := 0; time.Now().Before(deadline); i++ {
+		p := players[i%len(players)]
+		g.queueEffects(p.ID(), map[int][]core.IEffect{
+			p.ID(): {
+				&SetTeamEffect{Team: TeamID(i%2 + 1)},
+				&PlaceEffect{Position: ctf.config.Bases[i%2]},
+			},
+		})
+		g.HandleChatMessage(chat, p)
+		time.Sleep(time.Millisecond)
+	}
+	g.Shutdown()
+
+	if sent.Load() == 0 {
+		t.Fatal("team chat reached nobody")
+	}
+}
@@ -0,0 +1,138 @@
+package gamebase
+
+import (
+	"encoding/binary"
+	"time"
+
+	"game/core"
+	"game/protocol"
+)
+
+type FriendlyFire uint8
+
+const (
+	FriendlyFireOff FriendlyFire = iota
+	FriendlyFireOn
+	FriendlyFireReflect // damage dealt to a teammate hits the attacker instead
+)
+
+type DamageEffect struct {
+	Amount   int
+	SourceID int
+	OnKill   func(victimID, killerID int)
+}
+
+func (e *DamageEffect) Apply(obj core.GameObject) {
+	if target, IsDamageable := obj.(Damageable); IsDamageable {
+		if target.TakeDamage(e.Amount) && e.OnKill != nil {
+			e.OnKill(target.ID(), e.SourceID)
+		}
+	}
+}
+
+type death struct {
+	victimID int
+	killerID int
+}
+
+// DealDamage runs a hit through the friendly fire rules and queues it on the engine.
+// attackerID may be negative for environmental damage.
+func (g *Game) DealDamage(attackerID, victimID, amount int) {
+	if amount <= 0 || !g.Match.AcceptsDamage() {
+		return
+	}
+
+	if g.Teams.SameTeam(attackerID, victimID) {
+		switch g.Mode.FriendlyFire() {
+		case FriendlyFireOff:
+			return
+		case FriendlyFireReflect:
+			victimID = attackerID
+		}
+	}
+
+	if attackerID >= 0 && attackerID != victimID {
+		g.combatMu.Lock()
+		if g.damageLog[victimID] == nil {
+			g.damageLog[victimID] = make(map[int]uint64)
+		}
+		g.damageLog[victimID][attackerID] = g.Engine.Tick()
+		g.combatMu.Unlock()
+	}
+
+	g.queueEffects(attackerID, map[int][]core.IEffect{
+		victimID: {&DamageEffect{Amount: amount, SourceID: attackerID, OnKill: g.queueDeath}},
+	})
+}
+
+// SetCombatTimers changes the respawn delay and assist window, both are zero
+// until it is first called. Deaths already waiting to respawn keep their old delay.
+func (g *Game) SetCombatTimers(respawn, assist time.Duration) {
+	g.combatMu.Lock()
+	defer g.combatMu.Unlock()
+	g.respawnDelay = g.Match.durationToTicks(respawn)
+	g.assistWindow = g.Match.durationToTicks(assist)
+}
+
+// queueDeath runs on the engine's event consumer, deaths are
+// processed on the next fixed update.
+func (g *Game) queueDeath(victimID, killerID int) {
+	g.combatMu.Lock()
+	defer g.combatMu.Unlock()
+	g.deaths = append(g.deaths, death{victimID: victimID, killerID: killerID})
+}
+
+func (g *Game) processDeaths() {
+	g.combatMu.Lock()
+	deaths := g.deaths
+	g.deaths = nil
+
+	tick := g.Engine.Tick()
+	respawnAt := tick + g.respawnDelay
+	assists := make([][]int, len(deaths))
+	for i, d := range deaths {
+		for attackerID, at := range g.damageLog[d.victimID] {
+			if attackerID != d.killerID && tick-at <= g.assistWindow {
+				assists[i] = append(assists[i], attackerID)
+			}
+		}
+		delete(g.damageLog, d.victimID)
+	}
+	g.combatMu.Unlock()
+
+	for i, d := range deaths {
+		g.Scoreboard.RecordKill(d.killerID, d.victimID, assists[i])
+		g.Mode.OnPlayerDeath(g, d.victimID, d.killerID)
+		g.respawns[d.victimID] = respawnAt
+
+		// Payload: [4 bytes victim][4 bytes killer, -1 for none]
+		buf, offset := newMessage(protocol.MsgPlayerDied, 8)
+		binary.LittleEndian.PutUint32(buf[offset:offset+4], uint32(d.victimID))
+		binary.LittleEndian.PutUint32(buf[offset+4:offset+8], uint32(int32(d.killerID)))
+		g.broadcast(buf)
+	}
+}
+
+func (g *Game) processRespawns() {
+	if !g.Match.AcceptsDamage() {
+		return
+	}
+
+	tick := g.Engine.Tick()
+	for playerID, at := range g.respawns {
+		if tick < at {
+			continue
+		}
+		delete(g.respawns, playerID)
+
+		p := g.GetPlayerByID(playerID)
+		if p == nil {
+			continue
+		}
+
+		g.queueEffects(-1, map[int][]core.IEffect{
+			playerID: {&RespawnEffect{Position: g.Mode.SpawnPoint(g.Teams.TeamOf(playerID))}},
+		})
+		g.Scoreboard.RecordRespawn(playerID)
+	}
+}
@@ -14,3 +14,117 @@ func (e *MovementEffect) Apply(obj core.GameObject) {
 	}
 }
 
+type AnalogMovementEffect struct {
+	Direction core.Vector
+}
+
+func (e *AnalogMovementEffect) Apply(obj core.GameObject) {
+	if character, IsCharacter := obj.(Character); IsCharacter {
+		character.MoveAnalog(e.Direction)
+	}
+}
+
+// StatsEffect changes a player's base speed and health cap.
+type StatsEffect struct {
+	Speed     float32
+	MaxHealth int
+}
+
+func (e *StatsEffect) Apply(obj core.GameObject) {
+	if target, IsTunable := obj.(Tunable); IsTunable {
+		target.SetBaseSpeed(e.Speed)
+		target.SetMaxHealth(e.MaxHealth)
+	}
+}
+
+type AimEffect struct {
+	Direction core.Vector
+}
+
+func (e *AimEffect) Apply(obj core.GameObject) {
+	if character, IsCharacter := obj.(Character); IsCharacter {
+		character.SetFacing(e.Direction)
+	}
+}
+
+// AckEffect marks an input as processed. It is queued after the
+// input's other effects so the ack never runs ahead of them.
+type AckEffect struct {
+	Sequence uint32
+}
+
+func (e *AckEffect) Apply(obj core.GameObject) {
+	if acker, IsAcker := obj.(InputAcker); IsAcker {
+		acker.AckInput(e.Sequence)
+	}
+}
+
+type RespawnEffect struct {
+	Position core.Point
+}
+
+func (e *RespawnEffect) Apply(obj core.GameObject) {
+	if character, IsCharacter := obj.(Character); IsCharacter {
+		character.Move("move_stop")
+		character.SetPosition(e.Position)
+	}
+	if target, IsDamageable := obj.(Damageable); IsDamageable {
+		target.Revive()
+	}
+}
+
+type PlaceEffect struct {
+	Position core.Point
+}
+
+func (e *PlaceEffect) Apply(obj core.GameObject) {
+	if conc, IsConcrete := obj.(core.ConcreteObject); IsConcrete {
+		conc.SetPosition(e.Position)
+	}
+}
+
+type AttachEffect struct {
+	Child core.GameObject
+}
+
+func (e *AttachEffect) Apply(obj core.GameObject) {
+	obj.AddChild(e.Child)
+}
+
+type DetachEffect struct {
+	ChildID int
+}
+
+func (e *DetachEffect) Apply(obj core.GameObject) {
+	obj.RemoveChild(e.ChildID)
+}
+
+type SetTeamEffect struct {
+	Team TeamID
+}
+
+func (e *SetTeamEffect) Apply(obj core.GameObject) {
+	if member, IsTeamMember := obj.(TeamMember); IsTeamMember {
+		member.SetTeam(e.Team)
+	}
+}
+
+type HealEffect struct {
+	Amount int
+}
+
+func (e *HealEffect) Apply(obj core.GameObject) {
+	if target, IsDamageable := obj.(Damageable); IsDamageable {
+		target.Heal(e.Amount)
+	}
+}
+
+type ShieldEffect struct {
+	Amount int
+}
+
+func (e *ShieldEffect) Apply(obj core.GameObject) {
+	if target, IsShieldable := obj.(Shieldable); IsShieldable {
+		target.AddShield(e.Amount)
+	}
+}
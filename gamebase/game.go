@@ -2,23 +2,19 @@ package gamebase
 
 import (
 	"bytes"
-	"encoding/binary"
 	"encoding/json"
-	//"fmt"
 	"log"
+	"math"
 	"sync"
 	"time"
 
-	//"fmt"
-	//"maps"
-
 	"game/core"
 	"game/player"
+	"game/protocol"
 )
 
 type Message struct {
 	Type string             `json:"type"`
-	//Data map[int]core.GameObject `json:"data"`
 	Data []byte
 }
 
@@ -37,15 +33,52 @@ type Game struct {
 
 	PrevState         *State
 
+	Scoreboard         *Scoreboard
+	scoreboardInterval uint64
+
+	Match *Match
+	Mode  Mode
+	Teams *Teams
+
+	nextObjectID int
+	pickups      []*pickupSpawn
+
+	maxRewind uint64 // ticks
+
+	inputsMu sync.Mutex
+	inputs   map[int]*inputState
+	acksSent map[*player.Player]uint32 // owned by the fixed update
+
+	viewsMu    sync.Mutex
+	views      map[int]*view
+	viewRadius float32
+	compact    *compactCodec // nil sends full snapshots
+
+	fullStateInterval uint64 // ticks, 0 only sends full_state on request
+
+	arena    *Arena
+	fogOfWar bool
+
+	combatMu     sync.Mutex
+	damageLog    map[int]map[int]uint64 // victim -> attacker -> tick of last hit
+	deaths       []death
+	respawns     map[int]uint64 // player -> tick to respawn at, owned by the fixed update
+	respawnDelay uint64
+	assistWindow uint64
+
 	jsonBuffer        *bytes.Buffer
 	jsonEncoder       *json.Encoder
 
 	log *log.Logger
 
 	BroadcastFunc func([]byte)
+	SendFunc      func(*player.Player, []byte)
+	SpectateFunc  func([]byte)
+
+	spectatorKnown map[int]struct{} // objects the spectator stream has announced
 }
 
-func NewGame(state *State,fixedTPS float64, targetFPS, maxPlayers int, l *log.Logger) *Game {
+func NewGame(state *State,fixedTPS float64, targetFPS, maxPlayers int, match MatchConfig, l *log.Logger) *Game {
 	g := &Game{
 		State:         state,
 		maxPlayers:    maxPlayers,
@@ -53,6 +86,19 @@ func NewGame(state *State,fixedTPS float64, targetFPS, maxPlayers int, l *log.Lo
 		PlayerIDs:     make(map[int]string),
 		log:           l,
 		jsonBuffer: bytes.NewBuffer(make([]byte, 0, 2048)),
+		Scoreboard:         NewScoreboard(),
+		scoreboardInterval: uint64(math.Max(1, math.Round(fixedTPS))),
+		Match:              NewMatch(match, fixedTPS),
+		Mode:               &FreeForAll{},
+		Teams:              NewTeams(0, TeamRules{}),
+		nextObjectID:       maxPlayers,
+		damageLog:          make(map[int]map[int]uint64),
+		respawns:           make(map[int]uint64),
+		views:              make(map[int]*view),
+		inputs:             make(map[int]*inputState),
+		acksSent:           make(map[*player.Player]uint32),
+		viewRadius:         defaultViewRadius,
+		spectatorKnown:     make(map[int]struct{}),
 	}
 	g.jsonEncoder = json.NewEncoder(g.jsonBuffer)
 	g.Engine = *core.NewEngine(state.Base,fixedTPS,targetFPS)
@@ -63,6 +109,27 @@ func NewGame(state *State,fixedTPS float64, targetFPS, maxPlayers int, l *log.Lo
 	return g
 }
 
+// SetMode installs the rules for the match, call it before Start.
+func (g *Game) SetMode(mode Mode, rules TeamRules) {
+	g.Mode = mode
+	g.Teams = NewTeams(mode.TeamCount(), rules)
+	mode.Setup(g)
+}
+
+// NewObjectID hands out IDs for non-player objects, player slots use [0, maxPlayers).
+func (g *Game) NewObjectID() int {
+	id := g.nextObjectID
+	g.nextObjectID++
+	return id
+}
+
+// SetArena installs the world grid. With fogOfWar on, objects hidden
+// behind walls are never sent to a client, call it before Start.
+func (g *Game) SetArena(arena *Arena, fogOfWar bool) {
+	g.arena = arena
+	g.fogOfWar = fogOfWar
+}
+
 func (g *Game) Start() {
 	g.Engine.Run()
 }
@@ -87,67 +154,45 @@ func (g *Game) ReserveSpot() (int, bool) {
 }
 
 
-func (g *Game) AddPlayer(p *player.Player) {
+// AddPlayer fails with ErrNameTaken when another player already uses p's name.
+func (g *Game) AddPlayer(p *player.Player) error {
 	g.PlayersMu.Lock()
-	defer g.PlayersMu.Unlock()
+	if g.nameTakenLocked(p.Profile().Name, p.ID()) {
+		g.PlayersMu.Unlock()
+		return ErrNameTaken
+	}
 
 	g.State.Players[p.UserID()] = p
 	g.PlayerIDs[p.ID()] = p.UserID()
-	g.Engine.AddObject(p)
 
+	team := g.Teams.Assign(p.ID())
+	p.SetTeam(team)
+	p.SetPosition(g.Mode.SpawnPoint(team))
+	g.bindDefaultAbilities(p)
 
-	msgType := "player_joined"
-	msgTypeBytes := []byte(msgType)
-	msgTypeLen := uint32(len(msgTypeBytes))
-
-	payloadSize := p.Size()
-	totalSize := 4 + len(msgTypeBytes) + payloadSize
-
-	buf := make([]byte, totalSize)
-
-	binary.LittleEndian.PutUint32(buf[0:4], msgTypeLen)
-	copy(buf[4:4+len(msgTypeBytes)], msgTypeBytes)
-
-	offset := 4 + len(msgTypeBytes)
-	p.ToBytes(buf, offset)
+	g.Engine.AddObject(p)
+	g.Scoreboard.AddPlayer(p.ID())
+	g.PlayersMu.Unlock()
 
-	if g.BroadcastFunc != nil {
-		g.BroadcastFunc(buf)
-	}
+	g.broadcast(g.rosterMessage(protocol.MsgPlayerJoined, p))
+	return nil
 }
 
 
 
 func (g *Game) RemovePlayer(p *player.Player) {
 	g.PlayersMu.Lock()
-	defer g.PlayersMu.Unlock()
-
 	delete(g.State.Players, p.UserID())
 	delete(g.PlayerIDs, p.ID())
 
 	g.Engine.RemoveObject(p.ID())
+	g.Scoreboard.RemovePlayer(p.ID())
+	g.Teams.Remove(p.ID())
+	g.removeView(p.ID())
+	g.removeInput(p.ID())
+	g.PlayersMu.Unlock()
 
-	// --- Build binary message ---
-	msgType := "player_left"
-	msgTypeBytes := []byte(msgType)
-	msgTypeLen := uint32(len(msgTypeBytes))
-
-	payloadSize := p.Size()
-	totalSize := 4 + len(msgTypeBytes) + payloadSize
-
-	buf := make([]byte, totalSize)
-
-	// Header: [4 bytes msgLen][msgType]
-	binary.LittleEndian.PutUint32(buf[0:4], msgTypeLen)
-	copy(buf[4:4+len(msgTypeBytes)], msgTypeBytes)
-
-	// Payload: player info
-	offset := 4 + len(msgTypeBytes)
-	p.ToBytes(buf, offset)
-
-	if g.BroadcastFunc != nil {
-		g.BroadcastFunc(buf)
-	}
+	g.broadcast(g.rosterMessage(protocol.MsgPlayerLeft, p))
 }
 
 
@@ -167,70 +212,139 @@ func (g *Game) GetPlayerByID(playerID int) *player.Player {
     return g.State.Players[userID]
 }
 
-
-var (
-	bufPool = sync.Pool{
-	New: func() any {
-		return make([]byte, 4096) // Adjust size based on your needs
-	},
+// SetPlayerStats changes the base speed and health cap of every player in the game.
+func (g *Game) SetPlayerStats(speed float32, maxHealth int) {
+	effects := make(map[int][]core.IEffect)
+	for _, p := range g.Players() {
+		effects[p.ID()] = []core.IEffect{&StatsEffect{Speed: speed, MaxHealth: maxHealth}}
+	}
+	if len(effects) > 0 {
+		g.queueEffects(-1, effects)
+	}
 }
 
-)
-
-var (
-	typeStr      = "position_update"
-	typeBytes    = []byte(typeStr)
-	typeLen      = len(typeBytes)
-	typeLenBytes = make([]byte, 4)
-)
+// Players returns a snapshot of the connected players.
+func (g *Game) Players() []*player.Player {
+	g.PlayersMu.RLock()
+	defer g.PlayersMu.RUnlock()
 
-func init() {
-	binary.LittleEndian.PutUint32(typeLenBytes, uint32(typeLen))
+	players := make([]*player.Player, 0, len(g.State.Players))
+	for _, p := range g.State.Players {
+		players = append(players, p)
+	}
+	return players
 }
 
 
+
 func (g *Game) OnFixedUpdate(delta float64) {
-	payloadSize := 0
+	objects := g.Engine.Objects()
+	players := g.Players()
+
+	// The frame is read under the state lock so events applied meanwhile
+	// cannot tear it, the messages go out once the lock is released.
+	updates := make([][][]byte, len(players))
+	var spectated, acks [][]byte
+	g.Engine.WriteState(func() {
+		// DeltaSize refreshes each object's dirty flag against the previous tick
+		for _, obj := range objects {
+			obj.DeltaSize()
+		}
 
-	for _, conc := range g.State.Base.Objects {
-		payloadSize += conc.DeltaSize()
+		frame := g.snapshotObjects(objects)
+		for i, p := range players {
+			updates[i] = g.interestUpdates(p, players, objects, frame)
+		}
+		spectated = g.spectatorUpdates(objects)
+		acks = g.inputAcks(players)
+	})
+	for i, p := range players {
+		for _, msg := range updates[i] {
+			g.sendTo(p, msg)
+		}
 	}
-
-	totalSize := 4 + typeLen + payloadSize
-	buf := bufPool.Get().([]byte)[:totalSize]
-	offset := 0
-	for i := range buf {
-		buf[i] = 0
+	for _, msg := range spectated {
+		g.spectate(msg)
+	}
+	for i, p := range players {
+		if acks[i] != nil {
+			g.sendTo(p, acks[i])
+		}
 	}
 
-	copy(buf[offset:offset+4], typeLenBytes)
-	offset += 4
-	copy(buf[offset:offset+typeLen], typeBytes)
-	offset += typeLen
+	g.updateMatch()
 
+	g.processDeaths()
+	g.processRespawns()
+	if g.Match.AcceptsDamage() {
+		g.Mode.OnTick(g)
+		g.updatePickups()
+	}
 
-	initOffset := offset
-	for _, conc := range g.State.Base.Objects {
-		if !conc.IsDirty() {
-			continue
-		}
-	//	fmt.Printf("Inital Buffer just after message: %v\n",buf)
-		offset += conc.ToDeltaBytes(buf, offset)
-		//fmt.Printf("Raw bytes: % x\n", buf[:offset])
+	g.broadcastStatusChanges()
+	g.broadcastStateChanges()
+	g.sendAbilityChanges()
 
+	g.Scoreboard.Tick(delta)
+	periodic := g.Engine.Tick()%g.scoreboardInterval == 0
+	if periodic {
+		g.updateLatency()
+	}
+	if g.Scoreboard.TakeDirty() || periodic {
+		g.broadcastScoreboard()
 	}
+}
+
+
+
 
 
-	if g.BroadcastFunc != nil && offset > initOffset {
-		g.BroadcastFunc(buf[:offset])
+// OnPlayerConnected sends a freshly connected player the state
+// that is otherwise only pushed when it changes.
+func (g *Game) OnPlayerConnected(p *player.Player) {
+	g.sendTo(p, g.matchPhaseMessage())
+	g.sendTo(p, g.abilityMessage(p))
+	if g.arena != nil {
+		g.sendTo(p, g.arenaMessage())
 	}
+}
 
-	bufPool.Put(buf[:cap(buf)])
+// SuspendPlayer keeps a disconnected player in the world, standing still,
+// until it resumes or is removed.
+func (g *Game) SuspendPlayer(p *player.Player) {
+	g.removeInput(p.ID())
+	g.queueEffects(p.ID(), map[int][]core.IEffect{p.ID(): {&AnalogMovementEffect{Direction: core.DirStop}}})
 }
 
+// ResumePlayer brings a reconnected player back in sync. Its input sequence
+// starts over and it gets a full_state along with what OnPlayerConnected sends.
+func (g *Game) ResumePlayer(p *player.Player) {
+	g.removeInput(p.ID())
+	g.requestFullState(p, true)
+	g.OnPlayerConnected(p)
+}
 
+// rosterMessage announces p joining or leaving, followed by p's profile.
+func (g *Game) rosterMessage(msgType protocol.MsgType, p *player.Player) []byte {
+	profile := p.Profile()
 
+	buf, offset := newMessage(msgType, p.Size()+profileSize(profile))
+	g.putRosterEntry(buf, offset, p, profile)
+	return buf
+}
 
+// putRosterEntry writes [player][profile] and returns its size.
+// With fog of war the position is zeroed so the roster cannot be used to locate players.
+func (g *Game) putRosterEntry(buf []byte, offset int, p *player.Player, profile player.Profile) int {
+	n := p.ToBytes(buf, offset)
+
+	if g.fogOfWar {
+		// Skip [4 bytes id][1 byte type], clear [4 bytes X][4 bytes Y]
+		clear(buf[offset+5 : offset+13])
+	}
+
+	return n + encodeProfile(profile, buf, offset+n)
+}
 
 func (g *Game) OnVariableUpdate(delta float64) {
 }
@@ -241,7 +355,17 @@ func (g *Game) HandleInputEvent(clientEv *core.ClientEvent, p *player.Player) {
 	case "input_movement":
 		g.HandleInputMovement(clientEv,p)
 	case "chat_message":
-		g.log.Println("Not Yet Implemented")
+		g.HandleChatMessage(clientEv,p)
+	case "team_switch":
+		g.HandleTeamSwitch(clientEv,p)
+	case "input_ability":
+		g.HandleInputAbility(clientEv,p)
+	case "viewport":
+		g.HandleViewport(clientEv,p)
+	case "set_profile":
+		g.HandleSetProfile(clientEv,p)
+	case "full_state_request":
+		g.HandleFullStateRequest(clientEv,p)
 
 	default:
 		g.log.Println("Unknown client event type:", clientEv.Type, "from player", p.ID())
@@ -253,17 +377,28 @@ func (g *Game) HandleInputEvent(clientEv *core.ClientEvent, p *player.Player) {
 
 
 func (g *Game) HandleInputMovement(clientEv *core.ClientEvent, p *player.Player){
-	direction, ok := clientEv.Data["direction"].(string)
-	if !ok {
-		g.log.Println("Invalid direction in input_movement event from player", p.ID())
+	effect, err := parseMovement(clientEv.Data)
+	if err != nil {
+		g.log.Println("Invalid input_movement event from player", p.ID(), ":", err)
 		return
 	}
 	playerID := p.ID()
-	effect := &MovementEffect{Direction: direction}
+
+	// Movement the current phase or state ignores is still acknowledged
+	var effects []core.IEffect
+	if g.Match.AcceptsMovement() && p.State().AcceptsMovement() {
+		effects = append(effects, effect)
+	}
+	if seq, ok := clientEv.Data["seq"].(float64); ok && seq >= 0 && seq <= math.MaxUint32 {
+		effects = append(effects, &AckEffect{Sequence: uint32(seq)})
+	}
+	if len(effects) == 0 {
+		return
+	}
 
 	gameEvent := &core.Event{
 		Effects: map[int][]core.IEffect{
-			playerID: {effect},
+			playerID: effects,
 		},
 		Timestamp: time.Now().UnixNano(),
 		SourceID:  playerID,
@@ -272,3 +407,15 @@ func (g *Game) HandleInputMovement(clientEv *core.ClientEvent, p *player.Player)
 	g.Engine.HandleEvent(gameEvent)
 
 }
+
+func (g *Game) HandleTeamSwitch(clientEv *core.ClientEvent, p *player.Player){
+	team, ok := clientEv.Data["team"].(float64)
+	if !ok || team < 0 || team > 255 {
+		g.log.Println("Invalid team in team_switch event from player", p.ID())
+		return
+	}
+
+	if err := g.SwitchTeam(p, TeamID(team)); err != nil {
+		g.log.Println("Team switch rejected for player", p.ID(), ":", err)
+	}
+}
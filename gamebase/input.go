@@ -0,0 +1,157 @@
+package gamebase
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"slices"
+
+	"game/core"
+	"game/player"
+	"game/protocol"
+)
+
+// Stick inputs below this magnitude count as released.
+const analogDeadzone = 0.05
+
+var errInvalidMovement = errors.New("invalid movement payload")
+
+// parseMovement turns an input_movement payload into an effect. It accepts
+// the legacy {"direction": "move_up"} commands, a direction vector
+// {"x", "y"} and an {"angle", "magnitude"} pair with the angle in radians.
+// Analog input is clamped to unit length so clients can never exceed pxps.
+func parseMovement(data map[string]interface{}) (core.IEffect, error) {
+	if direction, ok := data["direction"].(string); ok {
+		return &MovementEffect{Direction: direction}, nil
+	}
+
+	var vec core.Vector
+
+	if x, y, ok := numberPair(data, "x", "y"); ok {
+		vec = core.Vector{VX: x, VY: y}
+	} else if angle, magnitude, ok := numberPair(data, "angle", "magnitude"); ok {
+		if magnitude < 0 {
+			return nil, errInvalidMovement
+		}
+		vec = core.VectorFromAngle(angle, min(magnitude, 1))
+	} else {
+		return nil, errInvalidMovement
+	}
+
+	vec.ClampLength(1)
+	if vec.Length() < analogDeadzone {
+		vec = core.DirStop
+	}
+
+	return &AnalogMovementEffect{Direction: vec}, nil
+}
+
+// inputState is what the game remembers about a client's binary input stream.
+type inputState struct {
+	sequence uint32
+	buttons  uint16
+}
+
+// HandleInput applies a binary input sample. Samples arriving out of order
+// are dropped and abilities fire when their button goes down, not while held.
+func (g *Game) HandleInput(in protocol.Input, p *player.Player) {
+	g.inputsMu.Lock()
+	state, seen := g.inputs[p.ID()]
+	if !seen {
+		state = &inputState{}
+		g.inputs[p.ID()] = state
+	} else if int32(in.Sequence-state.sequence) <= 0 {
+		g.inputsMu.Unlock()
+		return
+	}
+	pressed := in.Buttons &^ state.buttons
+	state.sequence = in.Sequence
+	state.buttons = in.Buttons
+	g.inputsMu.Unlock()
+
+	var effects []core.IEffect
+
+	if g.Match.AcceptsMovement() && p.State().AcceptsMovement() {
+		move := core.Vector{VX: in.MoveX, VY: in.MoveY}
+		move.ClampLength(1)
+		if move.Length() < analogDeadzone {
+			move = core.DirStop
+		}
+		effects = append(effects, &AnalogMovementEffect{Direction: move})
+
+		if in.Pressed(protocol.ButtonAim) {
+			effects = append(effects, &AimEffect{Direction: core.VectorFromAngle(in.Aim, 1)})
+		}
+	}
+
+	if g.Match.AcceptsMovement() && p.State().AcceptsAbility() {
+		if pressed&protocol.ButtonPrimary != 0 {
+			effects = append(effects, &UseAbilityEffect{Slot: SlotPrimary})
+		}
+		if pressed&protocol.ButtonSecondary != 0 {
+			effects = append(effects, &UseAbilityEffect{Slot: SlotSecondary})
+		}
+	}
+
+	// Inputs the current phase or state ignores are still acknowledged
+	effects = append(effects, &AckEffect{Sequence: in.Sequence})
+	g.queueEffects(p.ID(), map[int][]core.IEffect{p.ID(): effects})
+}
+
+// inputAcks builds, per player, the ack of its applied inputs with its
+// authoritative state after them, or nil when neither changed.
+// It must run under the engine's state lock.
+func (g *Game) inputAcks(players []*player.Player) [][]byte {
+	for p := range g.acksSent {
+		if !slices.Contains(players, p) {
+			delete(g.acksSent, p)
+		}
+	}
+
+	acks := make([][]byte, len(players))
+	for i, p := range players {
+		acked := p.AckedInput()
+		if sent, ok := g.acksSent[p]; ok && sent == acked && !p.IsDirty() {
+			continue
+		}
+		g.acksSent[p] = acked
+		acks[i] = inputAckMessage(p, acked)
+	}
+	return acks
+}
+
+// Payload: [4 bytes sequence][4 bytes X f32][4 bytes Y f32][4 bytes VX f32][4 bytes VY f32]
+func inputAckMessage(p *player.Player, seq uint32) []byte {
+	pos, vel := p.PositionXY(), p.Velocity()
+
+	buf, offset := newMessage(protocol.MsgInputAck, 20)
+	binary.LittleEndian.PutUint32(buf[offset:offset+4], seq)
+	binary.LittleEndian.PutUint32(buf[offset+4:offset+8], math.Float32bits(pos.X))
+	binary.LittleEndian.PutUint32(buf[offset+8:offset+12], math.Float32bits(pos.Y))
+	binary.LittleEndian.PutUint32(buf[offset+12:offset+16], math.Float32bits(vel.VX))
+	binary.LittleEndian.PutUint32(buf[offset+16:offset+20], math.Float32bits(vel.VY))
+	return buf
+}
+
+func (g *Game) removeInput(playerID int) {
+	g.inputsMu.Lock()
+	defer g.inputsMu.Unlock()
+	delete(g.inputs, playerID)
+}
+
+// numberPair reads two finite numbers from a JSON payload.
+func numberPair(data map[string]interface{}, a, b string) (float32, float32, bool) {
+	first, okA := data[a].(float64)
+	second, okB := data[b].(float64)
+	if !okA || !okB || !finite(first) || !finite(second) {
+		return 0, 0, false
+	}
+	if math.Abs(first) > math.MaxFloat32 || math.Abs(second) > math.MaxFloat32 {
+		return 0, 0, false
+	}
+	return float32(first), float32(second), true
+}
+
+func finite(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
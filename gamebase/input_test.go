@@ -0,0 +1,47 @@
+package gamebase
+
+import (
+	"testing"
+	"time"
+
+	"game/core"
+)
+
+func TestHandleInputMovementAcksGatedInput(t *testing.T) {
+	tests := []struct {
+		name   string
+		phase  Phase
+		moving bool
+	}{
+		{"accepted", PhaseInProgress, true},
+		{"countdown", PhaseCountdown, false},
+		{"results", PhaseResults, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame(t)
+			p := addTestPlayer(t, g, 0)
+			g.Match.setPhase(tt.phase, time.Minute)
+
+			g.Engine.OnFixedUpdate = func(float64) {}
+			g.Start()
+
+			move := &core.ClientEvent{Type: "input_movement", Data: map[string]any{"x": 1.0, "y": 0.0, "seq": 5.0}}
+			g.HandleInputMovement(move, p)
+
+			deadline := time.Now().Add(time.Second)
+			for p.AckedInput() != 5 && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+			}
+			g.Shutdown()
+
+			if got := p.AckedInput(); got != 5 {
+				t.Fatalf("acked input %d, want 5", got)
+			}
+			if moving := p.Velocity().Length() > 0; moving != tt.moving {
+				t.Fatalf("moving %v, want %v", moving, tt.moving)
+			}
+		})
+	}
+}
@@ -0,0 +1,482 @@
+package gamebase
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"game/core"
+	"game/player"
+	"game/protocol"
+)
+
+const (
+	defaultViewRadius = 1000
+
+	// Reported viewports are clamped so a client cannot ask to see the whole world
+	maxViewportWidth  = 3840
+	maxViewportHeight = 2160
+
+	// Objects just outside the viewport are kept so they do not pop in at the edge
+	viewportMargin = 100
+
+	// Snapshots sent but not yet acknowledged, a client that falls
+	// further behind is sent a keyframe instead of a delta
+	maxPendingSnapshots = 32
+)
+
+// snapshotRecord is one object at one tick, in both encodings.
+// fields is only filled in when compact snapshots are on.
+type snapshotRecord struct {
+	bytes  []byte
+	fields compactFields
+}
+
+// snapshotFrame maps object IDs to their records at one tick.
+// Frames are never modified after they are built, views share them.
+type snapshotFrame map[int]snapshotRecord
+
+// snapshotChange is an object a snapshot sends, with the compact fields to send.
+type snapshotChange struct {
+	id     int
+	record snapshotRecord
+	mask   uint64
+}
+
+type pendingSnapshot struct {
+	tick    uint64
+	objects snapshotFrame
+}
+
+// view is one client's area of interest.
+// Until the client reports a viewport the view is a circle of viewRadius.
+type view struct {
+	halfW, halfH float32
+
+	// baseline is the last snapshot the client acknowledged, nil until it
+	// acknowledges a keyframe. Updates are encoded as deltas against it.
+	baseline snapshotFrame
+	pending  []pendingSnapshot
+
+	// resync asks the next update to be a full_state, lastResync is the tick
+	// of the last one sent so clients cannot request them every tick
+	resync     bool
+	lastResync uint64
+}
+
+func (g *Game) SetViewRadius(radius float32) {
+	g.viewsMu.Lock()
+	defer g.viewsMu.Unlock()
+	g.viewRadius = radius
+}
+
+func (g *Game) HandleViewport(clientEv *core.ClientEvent, p *player.Player) {
+	width, height, ok := numberPair(clientEv.Data, "width", "height")
+	if !ok || width <= 0 || height <= 0 {
+		g.log.Println("Invalid viewport from player", p.ID())
+		return
+	}
+
+	width = min(width, maxViewportWidth)
+	height = min(height, maxViewportHeight)
+
+	g.viewsMu.Lock()
+	defer g.viewsMu.Unlock()
+
+	v := g.viewLocked(p.ID())
+	v.halfW = width/2 + viewportMargin
+	v.halfH = height/2 + viewportMargin
+}
+
+// viewLocked must be called with viewsMu held.
+func (g *Game) viewLocked(playerID int) *view {
+	v, ok := g.views[playerID]
+	if !ok {
+		v = &view{}
+		g.views[playerID] = v
+	}
+	return v
+}
+
+func (g *Game) removeView(playerID int) {
+	g.viewsMu.Lock()
+	defer g.viewsMu.Unlock()
+	delete(g.views, playerID)
+}
+
+// sees reports whether obj is inside viewer's area of interest.
+// Viewers always see themselves, objects without a position are seen by everyone.
+// It reads positions and must run under the engine's state lock.
+func (g *Game) sees(v *view, viewer *player.Player, obj core.GameObject) bool {
+	if obj.ID() == viewer.ID() {
+		return true
+	}
+
+	conc, ok := obj.(core.ConcreteObject)
+	if !ok {
+		return true
+	}
+
+	center, pos := viewer.PositionXY(), conc.PositionXY()
+	if v.halfW > 0 {
+		dx, dy := pos.X-center.X, pos.Y-center.Y
+		if dx < -v.halfW || dx > v.halfW || dy < -v.halfH || dy > v.halfH {
+			return false
+		}
+	} else if !withinRadius(center, pos, g.viewRadius) {
+		return false
+	}
+
+	if !g.fogOfWar || g.arena == nil {
+		return true
+	}
+
+	// Teammates are never hidden from each other
+	if member, ok := obj.(TeamMember); ok && member.Team() != TeamNone && member.Team() == viewer.Team() {
+		return true
+	}
+	return g.arena.LineOfSight(center, pos)
+}
+
+// broadcastAbout sends buf to the players that see subject and to spectators.
+// Events naming a player go through it so they cannot reveal hidden enemies,
+// the others learn what changed from updates once it comes into view.
+func (g *Game) broadcastAbout(subject core.GameObject, buf []byte) {
+	players := g.Players()
+	viewers := players[:0]
+
+	g.Engine.ReadState(func() {
+		g.viewsMu.Lock()
+		defer g.viewsMu.Unlock()
+
+		for _, p := range players {
+			if g.sees(g.viewLocked(p.ID()), p, subject) {
+				viewers = append(viewers, p)
+			}
+		}
+	})
+
+	for _, p := range viewers {
+		g.sendTo(p, buf)
+	}
+	g.spectate(buf)
+}
+
+// VisibleObjects returns the objects p may know about right now,
+// used to build the initial state before p has reported a viewport.
+func (g *Game) VisibleObjects(p *player.Player) []core.GameObject {
+	objects := g.Engine.Objects()
+
+	var visible []core.GameObject
+	g.Engine.ReadState(func() {
+		g.viewsMu.Lock()
+		defer g.viewsMu.Unlock()
+
+		v := &view{}
+		for _, obj := range objects {
+			if g.sees(v, p, obj) {
+				visible = append(visible, obj)
+			}
+		}
+	})
+	return visible
+}
+
+// viewDiff splits objects into those entering a view, those already in it
+// that changed, and the IDs of the objects that left it.
+type viewDiff struct {
+	inView  map[int]struct{}
+	entered []core.GameObject
+	updated []core.GameObject
+	left    []int
+}
+
+func diffView(known map[int]struct{}, objects []core.GameObject, sees func(core.GameObject) bool) viewDiff {
+	d := viewDiff{inView: make(map[int]struct{}, len(known))}
+
+	for _, obj := range objects {
+		if !sees(obj) {
+			continue
+		}
+		d.inView[obj.ID()] = struct{}{}
+
+		if _, ok := known[obj.ID()]; !ok {
+			d.entered = append(d.entered, obj)
+		} else if obj.IsDirty() {
+			d.updated = append(d.updated, obj)
+		}
+	}
+	for id := range known {
+		if _, still := d.inView[id]; !still {
+			d.left = append(d.left, id)
+		}
+	}
+
+	return d
+}
+
+// snapshotObjects serializes every object into one shared buffer.
+// It reads object state and must run under the engine's state lock.
+func (g *Game) snapshotObjects(objects []core.GameObject) snapshotFrame {
+	size := 0
+	for _, obj := range objects {
+		size += obj.Size()
+	}
+
+	buf := make([]byte, size)
+	frame := make(snapshotFrame, len(objects))
+	offset := 0
+	for _, obj := range objects {
+		n := obj.ToBytes(buf, offset)
+		record := snapshotRecord{bytes: buf[offset : offset+n : offset+n]}
+		if g.compact != nil {
+			record.fields = g.compact.fieldsOf(obj)
+		}
+		frame[obj.ID()] = record
+		offset += n
+	}
+	return frame
+}
+
+// interestUpdates builds the snapshot of the objects in p's view as a delta
+// against the last snapshot p acknowledged, none when nothing changed, or
+// a full state when p is due one. Changes keep being resent until acknowledged,
+// so a lost or late update is repaired by the next one. Objects that leave the
+// view and objects that no longer exist are listed apart.
+// It must run under the engine's state lock.
+//
+// The client applies deltas to whatever it holds, which may be any snapshot
+// sent since the baseline, so the delta is taken against all of them.
+func (g *Game) interestUpdates(p *player.Player, players []*player.Player, objects []core.GameObject, frame snapshotFrame) [][]byte {
+	g.viewsMu.Lock()
+	defer g.viewsMu.Unlock()
+	v := g.viewLocked(p.ID())
+
+	current := make(snapshotFrame)
+	for _, obj := range objects {
+		if g.sees(v, p, obj) {
+			current[obj.ID()] = frame[obj.ID()]
+		}
+	}
+
+	if g.wantsFullState(v, p) {
+		g.resetView(v, current)
+		return g.fullStateMessages(p, players, current)
+	}
+
+	if len(v.pending) >= maxPendingSnapshots {
+		v.baseline = nil
+		v.pending = v.pending[:0]
+	}
+
+	keyframe := v.baseline == nil
+	var held []snapshotFrame
+	if !keyframe {
+		held = append(held, v.baseline)
+		for _, s := range v.pending {
+			held = append(held, s.objects)
+		}
+	}
+
+	var changed []snapshotChange
+	for id, record := range current {
+		mask, ok := typeFields(record.fields.typ), true
+		if !keyframe {
+			mask, ok = g.changeSince(held, id, record)
+		}
+		if ok {
+			changed = append(changed, snapshotChange{id: id, record: record, mask: mask})
+		}
+	}
+
+	var left, despawned []int
+	gone := make(map[int]struct{})
+	for _, known := range held {
+		for id := range known {
+			if _, still := current[id]; !still {
+				gone[id] = struct{}{}
+			}
+		}
+	}
+	for id := range gone {
+		if _, exists := frame[id]; exists {
+			left = append(left, id)
+		} else {
+			despawned = append(despawned, id)
+		}
+	}
+
+	if !keyframe && len(left) == 0 && len(despawned) == 0 && len(changed) == 0 {
+		return nil
+	}
+	v.pending = append(v.pending, pendingSnapshot{tick: g.Engine.Tick(), objects: current})
+
+	if g.compact != nil {
+		return [][]byte{g.compact.snapshotMessage(keyframe, left, despawned, changed)}
+	}
+	return [][]byte{snapshotMessage(keyframe, left, despawned, changed)}
+}
+
+// changeSince reports whether record must be sent to a client holding any of
+// frames, and the compact fields to send. Objects missing from one of them are sent whole.
+func (g *Game) changeSince(frames []snapshotFrame, id int, record snapshotRecord) (uint64, bool) {
+	all := typeFields(record.fields.typ)
+
+	var mask uint64
+	for _, frame := range frames {
+		old, known := frame[id]
+		switch {
+		case !known:
+			return all, true
+		case g.compact != nil:
+			mask |= changedFields(old.fields, record.fields)
+		case !bytes.Equal(old.bytes, record.bytes):
+			return all, true
+		}
+	}
+	return mask, mask != 0
+}
+
+// AckSnapshot makes the snapshot p received at tick the baseline for its next deltas.
+// Acks for snapshots that are unknown or older than the baseline are ignored.
+func (g *Game) AckSnapshot(p *player.Player, tick uint32) {
+	g.viewsMu.Lock()
+	defer g.viewsMu.Unlock()
+
+	v, ok := g.views[p.ID()]
+	if !ok {
+		return
+	}
+	for i, s := range v.pending {
+		if uint32(s.tick) == tick {
+			v.baseline = s.objects
+			v.pending = append(v.pending[:0], v.pending[i+1:]...)
+			return
+		}
+	}
+}
+
+// Payload: [1 byte flags, 1 for a keyframe][2 bytes left count][4 bytes id per left object]
+// [2 bytes despawned count][4 bytes id per despawned object]
+// then the full serialization of every changed object.
+// A keyframe lists every object in view, clients drop any object it does not list.
+func snapshotMessage(keyframe bool, left, despawned []int, changed []snapshotChange) []byte {
+	size := 5 + 4*len(left) + 4*len(despawned)
+	for _, ch := range changed {
+		size += len(ch.record.bytes)
+	}
+
+	buf, offset := newMessage(protocol.MsgSnapshot, size)
+	if keyframe {
+		buf[offset] = 1
+	}
+	offset = putIDs(buf, offset+1, left)
+	offset = putIDs(buf, offset, despawned)
+	for _, ch := range changed {
+		offset += copy(buf[offset:], ch.record.bytes)
+	}
+	return buf
+}
+
+// putIDs writes [2 bytes count][4 bytes per id] and returns the offset after it.
+func putIDs(buf []byte, offset int, ids []int) int {
+	binary.LittleEndian.PutUint16(buf[offset:offset+2], uint16(len(ids)))
+	offset += 2
+	for _, id := range ids {
+		binary.LittleEndian.PutUint32(buf[offset:offset+4], uint32(id))
+		offset += 4
+	}
+	return offset
+}
+
+// spectatorUpdates streams the whole world, unfiltered, to spectators.
+// It must run under the engine's state lock like interestUpdates.
+func (g *Game) spectatorUpdates(objects []core.GameObject) [][]byte {
+	if g.SpectateFunc == nil {
+		return nil
+	}
+
+	d := diffView(g.spectatorKnown, objects, func(core.GameObject) bool { return true })
+	g.spectatorKnown = d.inView
+
+	return d.messages()
+}
+
+func (d viewDiff) messages() [][]byte {
+	var msgs [][]byte
+	if len(d.left) > 0 {
+		msgs = append(msgs, leaveViewMessage(d.left))
+	}
+	if len(d.entered) > 0 {
+		msgs = append(msgs, enterViewMessage(d.entered))
+	}
+	if len(d.updated) > 0 {
+		msgs = append(msgs, positionUpdateMessage(d.updated))
+	}
+	return msgs
+}
+
+// Payload: [4 bytes id] per object
+func leaveViewMessage(ids []int) []byte {
+	buf, offset := newMessage(protocol.MsgLeaveView, 4*len(ids))
+	for _, id := range ids {
+		binary.LittleEndian.PutUint32(buf[offset:offset+4], uint32(id))
+		offset += 4
+	}
+	return buf
+}
+
+// Payload: the full serialization of every object
+func enterViewMessage(objects []core.GameObject) []byte {
+	size := 0
+	for _, obj := range objects {
+		size += obj.Size()
+	}
+
+	buf, offset := newMessage(protocol.MsgEnterView, size)
+	for _, obj := range objects {
+		offset += obj.ToBytes(buf, offset)
+	}
+	return buf
+}
+
+// Payload: the delta serialization of every object
+func positionUpdateMessage(objects []core.GameObject) []byte {
+	size := 0
+	for _, obj := range objects {
+		size += obj.Size()
+	}
+
+	buf, offset := newMessage(protocol.MsgPositionUpdate, size)
+	for _, obj := range objects {
+		offset += obj.ToDeltaBytes(buf, offset)
+	}
+	// Clean objects write nothing, so the payload is usually shorter than size
+	protocol.PutHeader(buf, protocol.MsgPositionUpdate, 0, offset-protocol.HeaderSize)
+	return buf[:offset]
+}
+
+// SpectatorSnapshot returns the messages a spectator needs to catch up
+// with the world before following the regular stream.
+func (g *Game) SpectatorSnapshot() [][]byte {
+	objects := g.Engine.Objects()
+
+	var world []byte
+	g.Engine.ReadState(func() {
+		world = enterViewMessage(objects)
+	})
+
+	msgs := [][]byte{
+		g.matchPhaseMessage(),
+		world,
+		g.scoreboardMessage(),
+	}
+	if g.arena != nil {
+		msgs = append(msgs, g.arenaMessage())
+	}
+
+	tick := g.Engine.Tick()
+	for _, msg := range msgs {
+		protocol.SetTick(msg, tick)
+	}
+	return msgs
+}
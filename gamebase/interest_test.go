@@ -0,0 +1,111 @@
+package gamebase
+
+import (
+	"testing"
+
+	"game/core"
+	"game/player"
+	"game/protocol"
+)
+
+func TestBroadcastAboutFogOfWar(t *testing.T) {
+	// A wall fills column 5, x in [50, 60)
+	arena := NewArena(10, 10, 10)
+	arena.FillRect(5, 0, 1, 10)
+
+	tests := []struct {
+		name     string
+		fog      bool
+		subject  core.Point
+		sameTeam bool
+		sees     bool
+	}{
+		{"same side of the wall", true, core.Point{X: 35, Y: 50}, false, true},
+		{"behind the wall", true, core.Point{X: 85, Y: 50}, false, false},
+		{"behind the wall without fog", false, core.Point{X: 85, Y: 50}, false, true},
+		{"teammate behind the wall", true, core.Point{X: 85, Y: 50}, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame(t)
+			g.SetArena(arena, tt.fog)
+
+			viewer := addTestPlayer(t, g, 0)
+			subject := addTestPlayer(t, g, 1)
+
+			viewer.SetPosition(core.Point{X: 15, Y: 50})
+			subject.SetPosition(tt.subject)
+			viewer.SetTeam(1)
+			subject.SetTeam(2)
+			if tt.sameTeam {
+				subject.SetTeam(1)
+			}
+
+			got := make(map[int]bool)
+			g.SendFunc = func(p *player.Player, _ []byte) { got[p.ID()] = true }
+			buf, _ := newMessage(protocol.MsgStatusEffects, 0)
+			g.broadcastAbout(subject, buf)
+
+			if !got[subject.ID()] {
+				t.Fatal("subject did not hear about itself")
+			}
+			if got[viewer.ID()] != tt.sees {
+				t.Fatalf("viewer told %v, want %v", got[viewer.ID()], tt.sees)
+			}
+		})
+	}
+}
+
+func TestInterestUpdatesDeltaAgainstHeldSnapshots(t *testing.T) {
+	g := newTestGame(t)
+	p := addTestPlayer(t, g, 0)
+	start := core.Point{X: 100, Y: 100}
+	p.SetPosition(start)
+
+	update := func() []byte {
+		var msgs [][]byte
+		objects := g.Engine.Objects()
+		players := g.Players()
+		g.Engine.WriteState(func() {
+			msgs = g.interestUpdates(p, players, objects, g.snapshotObjects(objects))
+		})
+		if len(msgs) > 1 {
+			t.Fatalf("%d messages, want a single snapshot", len(msgs))
+		}
+		if len(msgs) == 0 {
+			return nil
+		}
+		return msgs[0]
+	}
+
+	steps := []struct {
+		name     string
+		move     core.Point
+		ack      bool
+		sent     bool
+		keyframe bool
+	}{
+		{"first update is a keyframe", start, true, true, true},
+		{"unchanged after the ack", start, false, false, false},
+		{"moved", core.Point{X: 150, Y: 100}, false, true, false},
+		{"moved back while the move is unacknowledged", start, false, true, false},
+	}
+
+	for _, s := range steps {
+		p.SetPosition(s.move)
+		msg := update()
+		if (msg != nil) != s.sent {
+			t.Fatalf("%s: sent %v, want %v", s.name, msg != nil, s.sent)
+		}
+		if msg == nil {
+			continue
+		}
+		if keyframe := msg[protocol.HeaderSize] == 1; keyframe != s.keyframe {
+			t.Fatalf("%s: keyframe %v, want %v", s.name, keyframe, s.keyframe)
+		}
+		if s.ack {
+			g.AckSnapshot(p, uint32(g.Engine.Tick()))
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package gamebase
+
+import (
+	"math"
+	"time"
+
+	"game/core"
+)
+
+// hitRadius is the radius of a character's hitbox.
+const hitRadius = 20
+
+// SetLagCompensation keeps depthTicks of position history per object and lets
+// clients rewind hit tests at most maxRewind into the past, call it before Start.
+func (g *Game) SetLagCompensation(depthTicks int, maxRewind time.Duration) {
+	g.Engine.SetHistoryDepth(depthTicks)
+	g.maxRewind = min(g.Match.durationToTicks(maxRewind), uint64(max(0, depthTicks-1)))
+}
+
+// RewindTick clamps the tick a client claims to have seen to the allowed window.
+func (g *Game) RewindTick(clientTick uint64) uint64 {
+	now := g.Engine.Tick()
+	if clientTick > now {
+		return now
+	}
+	if now-clientTick > g.maxRewind {
+		return now - g.maxRewind
+	}
+	return clientTick
+}
+
+// HitScan traces a ray from origin along direction for at most distance,
+// against where characters were at clientTick. It returns the first
+// character hit that is not blocked by a wall.
+func (g *Game) HitScan(shooterID int, clientTick uint64, origin core.Point, direction core.Vector, distance float32) (int, bool) {
+	length := direction.Length()
+	if length == 0 || distance <= 0 {
+		return -1, false
+	}
+	dx, dy := direction.VX/length, direction.VY/length
+
+	targetID, nearest := -1, float32(math.Inf(1))
+	g.Engine.Rewind(g.RewindTick(clientTick), func(obj core.GameObject, pos core.Point) {
+		target, ok := obj.(Damageable)
+		if !ok || obj.ID() == shooterID || !target.IsAlive() {
+			return
+		}
+		if t, hit := rayCircle(origin, dx, dy, pos, hitRadius); hit && t <= distance && t < nearest {
+			targetID, nearest = obj.ID(), t
+		}
+	})
+
+	if targetID < 0 {
+		return -1, false
+	}
+
+	impact := core.Point{X: origin.X + dx*nearest, Y: origin.Y + dy*nearest}
+	if g.arena != nil && !g.arena.LineOfSight(origin, impact) {
+		return -1, false
+	}
+	return targetID, true
+}
+
+// rayCircle returns the distance along the unit ray (dx, dy) from origin
+// at which it enters the circle, if it does.
+func rayCircle(origin core.Point, dx, dy float32, center core.Point, radius float32) (float32, bool) {
+	cx, cy := center.X-origin.X, center.Y-origin.Y
+
+	along := cx*dx + cy*dy
+	if along < 0 {
+		return 0, false
+	}
+
+	perpSq := cx*cx + cy*cy - along*along
+	if perpSq > radius*radius {
+		return 0, false
+	}
+
+	return max(0, along-float32(math.Sqrt(float64(radius*radius-perpSq)))), true
+}
@@ -0,0 +1,73 @@
+package gamebase
+
+import (
+	"testing"
+	"time"
+
+	"game/core"
+)
+
+func TestHitScanRewind(t *testing.T) {
+	g := newTestGame(t)
+	// 200 ticks per second, so 15ms is 3 ticks
+	g.SetLagCompensation(10, 15*time.Millisecond)
+
+	shooter := addTestPlayer(t, g, 0)
+	target := addTestPlayer(t, g, 1)
+	shooter.SetPosition(core.Point{X: 0, Y: 0})
+
+	// The target moves 100px right every tick, further than its hitbox
+	g.Engine.OnFixedUpdate = func(float64) {
+		g.Engine.WriteState(func() {
+			target.SetPosition(core.Point{X: 100 * float32(g.Engine.Tick()), Y: 500})
+		})
+	}
+	g.Start()
+	deadline := time.Now().Add(time.Second)
+	for g.Engine.Tick() < 12 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	g.Shutdown()
+
+	now := g.Engine.Tick()
+	if now < 12 {
+		t.Fatalf("engine reached tick %d", now)
+	}
+
+	tests := []struct {
+		name       string
+		clientTick uint64
+		rewindTick uint64
+	}{
+		{"current tick", now, now},
+		{"within max rewind", now - 2, now - 2},
+		{"at max rewind", now - 3, now - 3},
+		{"beyond max rewind", now - 8, now - 3},
+		{"from the future", now + 5, now},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := g.RewindTick(tt.clientTick); got != tt.rewindTick {
+				t.Fatalf("rewind tick %d, want %d", got, tt.rewindTick)
+			}
+
+			aimAt := func(tick uint64) (int, bool) {
+				pos, ok := g.Engine.PositionAt(target.ID(), tick)
+				if !ok {
+					t.Fatalf("no position recorded at tick %d", tick)
+				}
+				return g.HitScan(shooter.ID(), tt.clientTick, core.Point{}, core.Vector{VX: pos.X, VY: pos.Y}, 5000)
+			}
+
+			if id, hit := aimAt(tt.rewindTick); !hit || id != target.ID() {
+				t.Fatalf("shot at the rewound position hit %d %v, want %d", id, hit, target.ID())
+			}
+			if tt.clientTick != tt.rewindTick && tt.clientTick <= now {
+				if id, hit := aimAt(tt.clientTick); hit {
+					t.Fatalf("shot at the unclamped position hit %d", id)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package gamebase
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+
+	"game/protocol"
+)
+
+// updateLatency puts every player's ping on the scoreboard
+// and tells each player its own round trip and jitter.
+func (g *Game) updateLatency() {
+	for _, p := range g.Players() {
+		rtt, jitter := p.Latency()
+		g.Scoreboard.SetPing(p.ID(), int(rtt.Milliseconds()))
+		g.sendTo(p, pingMessage(rtt, jitter))
+	}
+}
+
+// Payload: [2 bytes round trip ms][2 bytes jitter ms]
+func pingMessage(rtt, jitter time.Duration) []byte {
+	buf, offset := newMessage(protocol.MsgPing, 4)
+	binary.LittleEndian.PutUint16(buf[offset:offset+2], uint16(min(rtt.Milliseconds(), math.MaxUint16)))
+	binary.LittleEndian.PutUint16(buf[offset+2:offset+4], uint16(min(jitter.Milliseconds(), math.MaxUint16)))
+	return buf
+}
@@ -0,0 +1,279 @@
+package gamebase
+
+import (
+	"encoding/binary"
+	"math"
+	"sync"
+	"time"
+
+	"game/core"
+	"game/player"
+	"game/protocol"
+)
+
+type Phase uint8
+
+const (
+	PhaseWaiting Phase = iota
+	PhaseCountdown
+	PhaseInProgress
+	PhaseOvertime
+	PhaseResults
+	PhaseReset
+)
+
+func (p Phase) String() string {
+	switch p {
+	case PhaseWaiting:
+		return "waiting"
+	case PhaseCountdown:
+		return "countdown"
+	case PhaseInProgress:
+		return "in_progress"
+	case PhaseOvertime:
+		return "overtime"
+	case PhaseResults:
+		return "results"
+	case PhaseReset:
+		return "reset"
+	default:
+		return "unknown"
+	}
+}
+
+type MatchConfig struct {
+	MinPlayers    int
+	Countdown     time.Duration
+	RoundDuration time.Duration
+	Overtime      time.Duration // zero disables overtime
+	Intermission  time.Duration
+}
+
+// Match drives the phases of a round. All durations are kept in ticks
+// so that the match stays in lockstep with the fixed update loop.
+type Match struct {
+	mu sync.RWMutex
+
+	config   MatchConfig
+	fixedTPS float64
+
+	phase     Phase
+	remaining uint64 // ticks left in the current phase, 0 if untimed
+}
+
+func NewMatch(config MatchConfig, fixedTPS float64) *Match {
+	if config.MinPlayers < 1 {
+		config.MinPlayers = 1
+	}
+	return &Match{
+		config:   config,
+		fixedTPS: fixedTPS,
+		phase:    PhaseWaiting,
+	}
+}
+
+// SetConfig replaces the match timers, the current phase keeps its remaining time.
+func (m *Match) SetConfig(config MatchConfig) {
+	if config.MinPlayers < 1 {
+		config.MinPlayers = 1
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.config = config
+}
+
+func (m *Match) Phase() Phase {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.phase
+}
+
+// Remaining returns the time left in the current phase.
+func (m *Match) Remaining() time.Duration {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.ticksToDuration(m.remaining)
+}
+
+// AcceptsMovement reports whether movement input should be applied.
+func (m *Match) AcceptsMovement() bool {
+	switch m.Phase() {
+	case PhaseCountdown, PhaseResults, PhaseReset:
+		return false
+	default:
+		return true
+	}
+}
+
+// AcceptsDamage reports whether combat counts in the current phase.
+func (m *Match) AcceptsDamage() bool {
+	switch m.Phase() {
+	case PhaseInProgress, PhaseOvertime:
+		return true
+	default:
+		return false
+	}
+}
+
+func (m *Match) durationToTicks(d time.Duration) uint64 {
+	return uint64(math.Ceil(d.Seconds() * m.fixedTPS))
+}
+
+func (m *Match) ticksToDuration(ticks uint64) time.Duration {
+	return time.Duration(float64(ticks) / m.fixedTPS * float64(time.Second))
+}
+
+// setPhase must be called with mu held.
+func (m *Match) setPhase(phase Phase, d time.Duration) {
+	m.phase = phase
+	m.remaining = m.durationToTicks(d)
+}
+
+// advance runs one tick of the state machine and reports whether the phase changed.
+// leaderTied tells it whether the top of the scoreboard is currently shared.
+func (m *Match) advance(playerCount int, leaderTied bool) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	before := m.phase
+
+	if m.remaining > 0 {
+		m.remaining--
+	}
+
+	switch m.phase {
+	case PhaseWaiting:
+		if playerCount >= m.config.MinPlayers {
+			m.setPhase(PhaseCountdown, m.config.Countdown)
+		}
+
+	case PhaseCountdown:
+		if playerCount < m.config.MinPlayers {
+			m.setPhase(PhaseWaiting, 0)
+		} else if m.remaining == 0 {
+			m.setPhase(PhaseInProgress, m.config.RoundDuration)
+		}
+
+	case PhaseInProgress:
+		if playerCount == 0 {
+			m.setPhase(PhaseReset, 0)
+		} else if m.remaining == 0 {
+			if leaderTied && m.config.Overtime > 0 {
+				m.setPhase(PhaseOvertime, m.config.Overtime)
+			} else {
+				m.setPhase(PhaseResults, m.config.Intermission)
+			}
+		}
+
+	case PhaseOvertime:
+		// Sudden death: the first change of lead ends the round
+		if playerCount == 0 {
+			m.setPhase(PhaseReset, 0)
+		} else if !leaderTied || m.remaining == 0 {
+			m.setPhase(PhaseResults, m.config.Intermission)
+		}
+
+	case PhaseResults:
+		if m.remaining == 0 {
+			m.setPhase(PhaseReset, 0)
+		}
+
+	case PhaseReset:
+		m.setPhase(PhaseWaiting, 0)
+	}
+
+	return m.phase != before
+}
+
+// leaderTied reports whether two or more players share the best score.
+// With fewer than two players there is nothing to break.
+func leaderTied(scores []PlayerScore) bool {
+	if len(scores) < 2 {
+		return false
+	}
+	return scores[0].Kills == scores[1].Kills && scores[0].Objective == scores[1].Objective
+}
+
+// teamsTied reports whether the two best teams share the lead.
+func teamsTied(scores []int) bool {
+	best, second := -1, -1
+	for _, score := range scores[1:] {
+		if score > best {
+			best, second = score, best
+		} else if score > second {
+			second = score
+		}
+	}
+	return second >= 0 && best == second
+}
+
+func (g *Game) updateMatch() {
+	g.PlayersMu.RLock()
+	playerCount := len(g.State.Players)
+	g.PlayersMu.RUnlock()
+
+	tied := leaderTied(g.Scoreboard.Snapshot())
+	if g.Teams.Count() > 0 {
+		tied = teamsTied(g.Teams.Scores())
+	}
+
+	if !g.Match.advance(playerCount, tied) {
+		return
+	}
+
+	phase := g.Match.Phase()
+	g.log.Println("Match phase:", phase)
+
+	switch phase {
+	case PhaseCountdown:
+		g.respawnAllPlayers()
+	case PhaseResults:
+		g.stopAllPlayers()
+	case PhaseInProgress:
+		g.Scoreboard.Reset()
+		g.Teams.Reset()
+		g.Mode.OnRoundStart(g)
+		g.resetPickups()
+		g.broadcastTeamScores()
+	case PhaseReset:
+		g.Scoreboard.Reset()
+	}
+
+	g.broadcast(g.matchPhaseMessage())
+}
+
+func (g *Game) stopAllPlayers() {
+	g.applyToPlayers(func(p *player.Player) core.IEffect {
+		return &MovementEffect{Direction: "move_stop"}
+	})
+}
+
+func (g *Game) respawnAllPlayers() {
+	clear(g.respawns)
+	g.applyToPlayers(func(p *player.Player) core.IEffect {
+		return &RespawnEffect{Position: g.Mode.SpawnPoint(g.Teams.TeamOf(p.ID()))}
+	})
+}
+
+// applyToPlayers queues one effect per player as a single engine event.
+func (g *Game) applyToPlayers(effectFor func(*player.Player) core.IEffect) {
+	g.PlayersMu.RLock()
+	effects := make(map[int][]core.IEffect, len(g.State.Players))
+	for _, p := range g.State.Players {
+		effects[p.ID()] = []core.IEffect{effectFor(p)}
+	}
+	g.PlayersMu.RUnlock()
+
+	g.queueEffects(-1, effects)
+}
+
+// Payload: [1 byte phase][4 bytes remaining ms]
+func (g *Game) matchPhaseMessage() []byte {
+	buf, offset := newMessage(protocol.MsgMatchPhase, 5)
+
+	buf[offset] = byte(g.Match.Phase())
+	binary.LittleEndian.PutUint32(buf[offset+1:offset+5], uint32(g.Match.Remaining().Milliseconds()))
+
+	return buf
+}
@@ -0,0 +1,120 @@
+package gamebase
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMatchAdvance(t *testing.T) {
+	// At 10 ticks per second every phase below lasts two ticks
+	config := MatchConfig{
+		MinPlayers:    2,
+		Countdown:     200 * time.Millisecond,
+		RoundDuration: 200 * time.Millisecond,
+		Overtime:      200 * time.Millisecond,
+		Intermission:  200 * time.Millisecond,
+	}
+
+	type step struct {
+		players int
+		tied    bool
+		want    Phase
+	}
+	tests := []struct {
+		name   string
+		config MatchConfig
+		steps  []step
+	}{
+		{"waits for enough players", config, []step{
+			{1, false, PhaseWaiting},
+			{2, false, PhaseCountdown},
+		}},
+		{"countdown aborts when a player leaves", config, []step{
+			{2, false, PhaseCountdown},
+			{1, false, PhaseWaiting},
+		}},
+		{"full round", config, []step{
+			{2, false, PhaseCountdown},
+			{2, false, PhaseCountdown},
+			{2, false, PhaseInProgress},
+			{2, false, PhaseInProgress},
+			{2, false, PhaseResults},
+			{2, false, PhaseResults},
+			{2, false, PhaseReset},
+			{2, false, PhaseWaiting},
+		}},
+		{"tie goes to overtime and ends on a new leader", config, []step{
+			{2, false, PhaseCountdown},
+			{2, false, PhaseCountdown},
+			{2, false, PhaseInProgress},
+			{2, true, PhaseInProgress},
+			{2, true, PhaseOvertime},
+			{2, false, PhaseResults},
+		}},
+		{"tie without overtime ends the round", MatchConfig{MinPlayers: 1, Countdown: 100 * time.Millisecond, RoundDuration: 100 * time.Millisecond}, []step{
+			{1, true, PhaseCountdown},
+			{1, true, PhaseInProgress},
+			{1, true, PhaseResults},
+		}},
+		{"empty round resets", config, []step{
+			{2, false, PhaseCountdown},
+			{2, false, PhaseCountdown},
+			{2, false, PhaseInProgress},
+			{0, false, PhaseReset},
+			{0, false, PhaseWaiting},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMatch(tt.config, 10)
+			for i, s := range tt.steps {
+				before := m.Phase()
+				changed := m.advance(s.players, s.tied)
+				if got := m.Phase(); got != s.want || changed != (got != before) {
+					t.Fatalf("step %d: phase %v, changed %v, want %v", i, got, changed, s.want)
+				}
+			}
+		})
+	}
+}
+
+func TestMatchAcceptsMovement(t *testing.T) {
+	tests := []struct {
+		phase Phase
+		want  bool
+	}{
+		{PhaseWaiting, true},
+		{PhaseCountdown, false},
+		{PhaseInProgress, true},
+		{PhaseOvertime, true},
+		{PhaseResults, false},
+		{PhaseReset, false},
+	}
+	for _, tt := range tests {
+		m := NewMatch(MatchConfig{}, 10)
+		m.phase = tt.phase
+		if got := m.AcceptsMovement(); got != tt.want {
+			t.Errorf("%v: AcceptsMovement = %v, want %v", tt.phase, got, tt.want)
+		}
+	}
+}
+
+func TestLeaderTied(t *testing.T) {
+	tests := []struct {
+		name   string
+		scores []PlayerScore
+		want   bool
+	}{
+		{"nobody", nil, false},
+		{"alone", []PlayerScore{{Kills: 3}}, false},
+		{"tied", []PlayerScore{{Kills: 3, Objective: 1}, {Kills: 3, Objective: 1}}, true},
+		{"ahead on kills", []PlayerScore{{Kills: 4}, {Kills: 3}}, false},
+		{"ahead on objective", []PlayerScore{{Kills: 3, Objective: 2}, {Kills: 3, Objective: 1}}, false},
+	}
+	for _, tt := range tests {
+		if got := leaderTied(tt.scores); got != tt.want {
+			t.Errorf("%s: leaderTied = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package gamebase
+
+import (
+	"time"
+
+	"game/core"
+	"game/player"
+	"game/protocol"
+)
+
+// newMessage allocates a frame for msgType with room for payloadSize bytes,
+// see protocol.PutHeader for the layout. The tick is stamped when the frame is sent.
+// It returns the buffer and the offset at which the payload starts.
+func newMessage(msgType protocol.MsgType, payloadSize int) ([]byte, int) {
+	return protocol.New(msgType, 0, payloadSize)
+}
+
+func (g *Game) broadcast(buf []byte) {
+	if g.BroadcastFunc != nil {
+		protocol.SetTick(buf, g.Engine.Tick())
+		g.BroadcastFunc(buf)
+	}
+}
+
+func (g *Game) sendTo(p *player.Player, buf []byte) {
+	if g.SendFunc != nil {
+		protocol.SetTick(buf, g.Engine.Tick())
+		g.SendFunc(p, buf)
+	}
+}
+
+func (g *Game) spectate(buf []byte) {
+	if g.SpectateFunc != nil {
+		protocol.SetTick(buf, g.Engine.Tick())
+		g.SpectateFunc(buf)
+	}
+}
+
+// queueEffects hands effects to the engine as a single event.
+func (g *Game) queueEffects(sourceID int, effects map[int][]core.IEffect) {
+	g.Engine.HandleEvent(&core.Event{
+		Effects:   effects,
+		Timestamp: time.Now().UnixNano(),
+		SourceID:  sourceID,
+	})
+}
@@ -0,0 +1,75 @@
+package gamebase
+
+import (
+	"fmt"
+
+	"game/core"
+)
+
+// Mode holds the rules that differ between game types.
+// All hooks run on the fixed update loop.
+type Mode interface {
+	Name() string
+	TeamCount() int
+	FriendlyFire() FriendlyFire
+	SpawnPoint(team TeamID) core.Point
+	Setup(g *Game)
+	OnRoundStart(g *Game)
+	OnTick(g *Game)
+	OnPlayerDeath(g *Game, victimID, killerID int)
+}
+
+// baseMode provides no-op hooks for modes to embed.
+type baseMode struct{}
+
+func (baseMode) FriendlyFire() FriendlyFire                    { return FriendlyFireOff }
+func (baseMode) SpawnPoint(team TeamID) core.Point             { return core.Point{X: 0, Y: 0} }
+func (baseMode) Setup(g *Game)                                 {}
+func (baseMode) OnRoundStart(g *Game)                          {}
+func (baseMode) OnTick(g *Game)                                {}
+func (baseMode) OnPlayerDeath(g *Game, victimID, killerID int) {}
+
+func NewMode(name string) (Mode, error) {
+	switch name {
+	case "ffa":
+		return &FreeForAll{}, nil
+	case "tdm":
+		return &TeamDeathmatch{}, nil
+	case "ctf":
+		return NewCaptureTheFlag(DefaultCTFConfig()), nil
+	default:
+		return nil, fmt.Errorf("unknown game mode %q", name)
+	}
+}
+
+// HasMode reports whether NewMode knows name.
+func HasMode(name string) bool {
+	_, err := NewMode(name)
+	return err == nil
+}
+
+type FreeForAll struct {
+	baseMode
+}
+
+func (m *FreeForAll) Name() string   { return "ffa" }
+func (m *FreeForAll) TeamCount() int { return 0 }
+
+// Every hit counts in free for all, so friendly fire is reported as on.
+func (m *FreeForAll) FriendlyFire() FriendlyFire { return FriendlyFireOn }
+
+type TeamDeathmatch struct {
+	baseMode
+}
+
+func (m *TeamDeathmatch) Name() string   { return "tdm" }
+func (m *TeamDeathmatch) TeamCount() int { return 2 }
+
+func (m *TeamDeathmatch) OnPlayerDeath(g *Game, victimID, killerID int) {
+	if killerID == victimID || g.Teams.SameTeam(killerID, victimID) {
+		return
+	}
+	if team := g.Teams.TeamOf(killerID); team != TeamNone {
+		g.addTeamScore(team, 1)
+	}
+}
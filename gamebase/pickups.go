@@ -0,0 +1,230 @@
+package gamebase
+
+import (
+	"encoding/binary"
+	"time"
+
+	"game/core"
+	"game/player"
+	"game/protocol"
+)
+
+type PickupKind uint8
+
+const (
+	PickupHealth PickupKind = iota
+	PickupSpeed
+	PickupShield
+)
+
+const (
+	pickupRadius = 30
+
+	healthPackAmount   = 50
+	speedBoostFactor   = 1.5
+	speedBoostDuration = 5 * time.Second
+	shieldAmount       = 50
+)
+
+type Pickup struct {
+	core.Concrete
+	kind   PickupKind
+	active bool
+}
+
+func NewPickup(id int, kind PickupKind, pos core.Point) *Pickup {
+	p := &Pickup{
+		Concrete: *core.NewConcreteObject(id, nil, pos),
+		kind:     kind,
+		active:   true,
+	}
+	p.SetType(core.TypePickup)
+	return p
+}
+
+func (p *Pickup) Kind() PickupKind {
+	return p.kind
+}
+
+func (p *Pickup) Active() bool {
+	return p.active
+}
+
+// pickupEffects returns what collecting a pickup of kind does to a character.
+func (g *Game) pickupEffects(kind PickupKind) []core.IEffect {
+	switch kind {
+	case PickupHealth:
+		return []core.IEffect{&HealEffect{Amount: healthPackAmount}}
+	case PickupSpeed:
+		return []core.IEffect{&core.ApplyStatusEffect{Status: NewSpeedBoost(speedBoostFactor, g.ticks(speedBoostDuration))}}
+	case PickupShield:
+		return []core.IEffect{&ShieldEffect{Amount: shieldAmount}}
+	default:
+		return nil
+	}
+}
+
+// wants reports whether collecting the pickup would do anything for pl,
+// so that full health players walk over health packs without using them.
+func (p *Pickup) wants(pl *player.Player) bool {
+	switch p.kind {
+	case PickupHealth:
+		return pl.Health() < pl.MaxHealth()
+	case PickupShield:
+		return pl.Shield() < player.MaxShield
+	default:
+		return true
+	}
+}
+
+//Serializable
+// Pickup layout: [Concrete][1 byte kind][1 byte active]
+
+func (p *Pickup) ToBytes(buf []byte, start int) int {
+	n := p.Concrete.ToBytes(buf, start)
+	buf[start+n] = byte(p.kind)
+	if p.active {
+		buf[start+n+1] = 1
+	}
+	return n + 2
+}
+
+func (p *Pickup) ToDeltaBytes(buf []byte, start int) int {
+	if !p.IsDirty() {
+		return 0
+	}
+	return p.ToBytes(buf, start)
+}
+
+func (p *Pickup) Size() int {
+	return p.Concrete.Size() + 2
+}
+
+func (p *Pickup) DeltaSize() int {
+	return p.Concrete.DeltaSize() + 2
+}
+
+type PickupConfig struct {
+	Kind     PickupKind
+	Position core.Point
+	Respawn  time.Duration
+}
+
+func DefaultPickups() []PickupConfig {
+	return []PickupConfig{
+		{Kind: PickupHealth, Position: core.Point{X: 600, Y: 150}, Respawn: 20 * time.Second},
+		{Kind: PickupHealth, Position: core.Point{X: 600, Y: 450}, Respawn: 20 * time.Second},
+		{Kind: PickupSpeed, Position: core.Point{X: 400, Y: 300}, Respawn: 30 * time.Second},
+		{Kind: PickupShield, Position: core.Point{X: 800, Y: 300}, Respawn: 40 * time.Second},
+	}
+}
+
+type pickupSpawn struct {
+	pickup       *Pickup
+	respawnTicks uint64
+	respawnAt    uint64
+}
+
+// AddPickups places collectible items in the world, call it before Start.
+func (g *Game) AddPickups(configs []PickupConfig) {
+	for _, cfg := range configs {
+		pickup := NewPickup(g.NewObjectID(), cfg.Kind, cfg.Position)
+		g.Engine.AddObject(pickup)
+		g.pickups = append(g.pickups, &pickupSpawn{
+			pickup:       pickup,
+			respawnTicks: g.Match.durationToTicks(cfg.Respawn),
+		})
+	}
+}
+
+type pickupTake struct {
+	pickup    *Pickup
+	collector *player.Player
+}
+
+func (g *Game) updatePickups() {
+	if len(g.pickups) == 0 {
+		return
+	}
+
+	players := g.Players()
+	tick := g.Engine.Tick()
+
+	// Collectors are picked and pickups flipped under the state lock so a
+	// concurrent full state never sees a half taken pickup.
+	var respawned []*Pickup
+	var taken []pickupTake
+	g.Engine.WriteState(func() {
+		for _, spawn := range g.pickups {
+			pickup := spawn.pickup
+
+			if !pickup.active {
+				if tick >= spawn.respawnAt {
+					pickup.active = true
+					respawned = append(respawned, pickup)
+				}
+				continue
+			}
+
+			for _, p := range players {
+				if !p.IsAlive() || !pickup.wants(p) ||
+					!withinRadius(p.PositionXY(), pickup.PositionXY(), pickupRadius) {
+					continue
+				}
+
+				pickup.active = false
+				spawn.respawnAt = tick + spawn.respawnTicks
+				taken = append(taken, pickupTake{pickup: pickup, collector: p})
+				break
+			}
+		}
+	})
+
+	for _, pickup := range respawned {
+		g.broadcastPickupEvent(protocol.MsgPickupRespawned, pickup, nil)
+	}
+	for _, take := range taken {
+		g.queueEffects(take.pickup.ID(), map[int][]core.IEffect{
+			take.collector.ID(): g.pickupEffects(take.pickup.kind),
+		})
+		g.broadcastPickupEvent(protocol.MsgPickupTaken, take.pickup, take.collector)
+	}
+}
+
+// resetPickups makes every pickup available again for a new round.
+func (g *Game) resetPickups() {
+	var respawned []*Pickup
+	g.Engine.WriteState(func() {
+		for _, spawn := range g.pickups {
+			if !spawn.pickup.active {
+				spawn.pickup.active = true
+				respawned = append(respawned, spawn.pickup)
+			}
+		}
+	})
+
+	for _, pickup := range respawned {
+		g.broadcastPickupEvent(protocol.MsgPickupRespawned, pickup, nil)
+	}
+}
+
+// broadcastPickupEvent tells everyone about pickup, or with a collector
+// only the players that see the collector.
+// Payload: [4 bytes pickup][1 byte kind][4 bytes player, -1 for none]
+func (g *Game) broadcastPickupEvent(msgType protocol.MsgType, pickup *Pickup, collector *player.Player) {
+	playerID := -1
+	if collector != nil {
+		playerID = collector.ID()
+	}
+
+	buf, offset := newMessage(msgType, 9)
+	binary.LittleEndian.PutUint32(buf[offset:offset+4], uint32(pickup.ID()))
+	buf[offset+4] = byte(pickup.kind)
+	binary.LittleEndian.PutUint32(buf[offset+5:offset+9], uint32(int32(playerID)))
+
+	if collector != nil {
+		g.broadcastAbout(collector, buf)
+		return
+	}
+	g.broadcast(buf)
+}
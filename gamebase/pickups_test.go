@@ -0,0 +1,60 @@
+package gamebase
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"game/core"
+	"game/player"
+	"game/protocol"
+)
+
+func TestUpdatePickupsHealthPack(t *testing.T) {
+	tests := []struct {
+		name   string
+		damage int
+		taken  bool
+	}{
+		{"full health walks over it", 0, false},
+		{"hurt player takes it", 30, true},
+		{"dead player is ignored", player.DefaultMaxHealth, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame(t)
+			g.AddPickups([]PickupConfig{{Kind: PickupHealth, Position: core.Point{X: 10, Y: 10}, Respawn: time.Second}})
+
+			p := addTestPlayer(t, g, 0)
+			p.TakeDamage(tt.damage)
+
+			var events []protocol.MsgType
+			g.BroadcastFunc = func(buf []byte) { events = append(events, messageType(t, buf)) }
+			g.SendFunc = func(_ *player.Player, buf []byte) { events = append(events, messageType(t, buf)) }
+
+			g.updatePickups()
+			g.updatePickups()
+
+			pickup := g.pickups[0].pickup
+			if pickup.Active() == tt.taken {
+				t.Fatalf("pickup active %v, want %v", pickup.Active(), !tt.taken)
+			}
+			want := []protocol.MsgType{}
+			if tt.taken {
+				want = append(want, protocol.MsgPickupTaken)
+			}
+			if !slices.Equal(events, want) {
+				t.Fatalf("broadcast %v, want %v", events, want)
+			}
+		})
+	}
+}
+
+func messageType(t *testing.T, buf []byte) protocol.MsgType {
+	h, err := protocol.ParseHeader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h.Type
+}
@@ -0,0 +1,191 @@
+package gamebase
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"game/core"
+	"game/player"
+	"game/protocol"
+)
+
+const (
+	minNameLength = 3
+	maxNameLength = 16 // runes
+
+	ColorCount  = 8
+	SkinCount   = 4
+	AvatarCount = 8 // avatar 0 is no avatar
+)
+
+var (
+	ErrNameLength     = errors.New("name must be 3 to 16 characters")
+	ErrNameCharacters = errors.New("name may only contain letters, digits, spaces, '-' and '_'")
+	ErrNameFiltered   = errors.New("name is not allowed")
+	ErrNameTaken      = errors.New("name is already taken")
+	ErrInvalidColor   = errors.New("invalid color")
+	ErrInvalidSkin    = errors.New("invalid skin")
+	ErrInvalidAvatar  = errors.New("invalid avatar")
+	ErrProfileLocked  = errors.New("profile can only change between matches")
+)
+
+// blockedWords are matched against names with case, separators and
+// common digit substitutions removed.
+var blockedWords = []string{
+	"admin",
+	"moderator",
+	"server",
+	"fuck",
+	"shit",
+	"bitch",
+	"cunt",
+	"nigg",
+	"fag",
+}
+
+var leetReplacer = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s")
+
+// NewProfile validates and normalizes a profile chosen by a client.
+// Uniqueness is checked when the profile is applied.
+func NewProfile(name string, color, skin, avatar int) (player.Profile, error) {
+	name = strings.Join(strings.Fields(name), " ")
+
+	if n := utf8.RuneCountInString(name); n < minNameLength || n > maxNameLength {
+		return player.Profile{}, ErrNameLength
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != ' ' && r != '-' && r != '_' {
+			return player.Profile{}, ErrNameCharacters
+		}
+	}
+	if nameBlocked(name) {
+		return player.Profile{}, ErrNameFiltered
+	}
+
+	switch {
+	case color < 0 || color >= ColorCount:
+		return player.Profile{}, ErrInvalidColor
+	case skin < 0 || skin >= SkinCount:
+		return player.Profile{}, ErrInvalidSkin
+	case avatar < 0 || avatar >= AvatarCount:
+		return player.Profile{}, ErrInvalidAvatar
+	}
+
+	return player.Profile{Name: name, Color: uint8(color), Skin: uint8(skin), Avatar: uint8(avatar)}, nil
+}
+
+// DefaultProfile is used when a player joins without choosing a name.
+func DefaultProfile(slot int) player.Profile {
+	return player.Profile{Name: "Player " + strconv.Itoa(slot+1), Color: uint8(slot % ColorCount)}
+}
+
+func nameBlocked(name string) bool {
+	normalized := leetReplacer.Replace(strings.ToLower(name))
+	normalized = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) {
+			return r
+		}
+		return -1
+	}, normalized)
+
+	for _, word := range blockedWords {
+		if strings.Contains(normalized, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// nameTakenLocked must be called with PlayersMu held.
+func (g *Game) nameTakenLocked(name string, exceptID int) bool {
+	for _, p := range g.State.Players {
+		if p.ID() != exceptID && strings.EqualFold(p.Profile().Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetProfile changes p's profile between matches and announces it.
+func (g *Game) SetProfile(p *player.Player, profile player.Profile) error {
+	switch g.Match.Phase() {
+	case PhaseCountdown, PhaseInProgress, PhaseOvertime:
+		return ErrProfileLocked
+	}
+
+	g.PlayersMu.Lock()
+	if g.nameTakenLocked(profile.Name, p.ID()) {
+		g.PlayersMu.Unlock()
+		return ErrNameTaken
+	}
+	p.SetProfile(profile)
+	g.PlayersMu.Unlock()
+
+	g.broadcast(profileMessage(p))
+	return nil
+}
+
+// Profiles returns every player's profile keyed by player ID.
+func (g *Game) Profiles() map[int]player.Profile {
+	g.PlayersMu.RLock()
+	defer g.PlayersMu.RUnlock()
+
+	out := make(map[int]player.Profile, len(g.State.Players))
+	for _, p := range g.State.Players {
+		out[p.ID()] = p.Profile()
+	}
+	return out
+}
+
+func (g *Game) HandleSetProfile(clientEv *core.ClientEvent, p *player.Player) {
+	name, _ := clientEv.Data["name"].(string)
+	color, _ := clientEv.Data["color"].(float64)
+	skin, _ := clientEv.Data["skin"].(float64)
+	avatar, _ := clientEv.Data["avatar"].(float64)
+
+	profile, err := NewProfile(name, int(color), int(skin), int(avatar))
+	if err == nil {
+		err = g.SetProfile(p, profile)
+	}
+	if err != nil {
+		g.log.Println("Rejected profile from player", p.ID(), ":", err)
+		g.sendTo(p, profileErrorMessage(err))
+	}
+}
+
+func profileSize(profile player.Profile) int {
+	return 4 + len(profile.Name)
+}
+
+// Profile layout: [1 byte color][1 byte skin][1 byte avatar][1 byte name length][name]
+func encodeProfile(profile player.Profile, buf []byte, start int) int {
+	buf[start] = profile.Color
+	buf[start+1] = profile.Skin
+	buf[start+2] = profile.Avatar
+	buf[start+3] = byte(len(profile.Name))
+	copy(buf[start+4:], profile.Name)
+	return profileSize(profile)
+}
+
+// Payload: [4 bytes player][profile]
+func profileMessage(p *player.Player) []byte {
+	profile := p.Profile()
+
+	buf, offset := newMessage(protocol.MsgPlayerProfile, 4+profileSize(profile))
+	binary.LittleEndian.PutUint32(buf[offset:offset+4], uint32(p.ID()))
+	encodeProfile(profile, buf, offset+4)
+	return buf
+}
+
+// Payload: the error text
+func profileErrorMessage(err error) []byte {
+	text := err.Error()
+
+	buf, offset := newMessage(protocol.MsgProfileError, len(text))
+	copy(buf[offset:], text)
+	return buf
+}
@@ -0,0 +1,96 @@
+package gamebase
+
+import (
+	"encoding/binary"
+	"time"
+
+	"game/core"
+	"game/player"
+	"game/protocol"
+)
+
+// Clients may ask for a full_state at most this often.
+const minResyncInterval = time.Second
+
+// SetFullStateInterval sends every player a full_state every interval, staggered
+// by player so they do not all go out on the same tick. Zero disables it, call it before Start.
+func (g *Game) SetFullStateInterval(interval time.Duration) {
+	g.fullStateInterval = g.Match.durationToTicks(interval)
+}
+
+// HandleFullStateRequest answers a client that lost track of the world
+// with a full_state on the next update.
+func (g *Game) HandleFullStateRequest(clientEv *core.ClientEvent, p *player.Player) {
+	g.requestFullState(p, false)
+}
+
+// requestFullState marks p for a full_state on the next update. Unless forced
+// it is ignored when p was sent one less than minResyncInterval ago.
+func (g *Game) requestFullState(p *player.Player, force bool) {
+	g.viewsMu.Lock()
+	defer g.viewsMu.Unlock()
+
+	v := g.viewLocked(p.ID())
+	if !force && v.lastResync != 0 && g.Engine.Tick()-v.lastResync < g.Match.durationToTicks(minResyncInterval) {
+		return
+	}
+	v.resync = true
+}
+
+// wantsFullState must be called with viewsMu held.
+func (g *Game) wantsFullState(v *view, p *player.Player) bool {
+	if v.resync {
+		return true
+	}
+	return g.fullStateInterval > 0 && (g.Engine.Tick()+uint64(p.ID()))%g.fullStateInterval == 0
+}
+
+// resetView makes current the only snapshot v waits an ack for, so deltas
+// restart from the full_state once the client acknowledges it.
+// It must be called with viewsMu held.
+func (g *Game) resetView(v *view, current snapshotFrame) {
+	tick := g.Engine.Tick()
+	v.resync = false
+	v.lastResync = tick
+	v.baseline = nil
+	v.pending = append(v.pending[:0], pendingSnapshot{tick: tick, objects: current})
+}
+
+// fullStateMessages returns everything p needs to rebuild its view of the game,
+// along with the state that is otherwise only pushed when it changes.
+// It reads object state and must run under the engine's state lock.
+func (g *Game) fullStateMessages(p *player.Player, players []*player.Player, current snapshotFrame) [][]byte {
+	return [][]byte{
+		g.fullStateMessage(players, current),
+		g.matchPhaseMessage(),
+		g.abilityMessage(p),
+	}
+}
+
+// Payload: [2 bytes player count] then per player its roster entry
+// [player][profile], then the full serialization of every object in view.
+// Clients drop every object the message does not list.
+func (g *Game) fullStateMessage(players []*player.Player, current snapshotFrame) []byte {
+	profiles := make([]player.Profile, len(players))
+
+	size := 2
+	for i, p := range players {
+		profiles[i] = p.Profile()
+		size += p.Size() + profileSize(profiles[i])
+	}
+	for _, record := range current {
+		size += len(record.bytes)
+	}
+
+	buf, offset := newMessage(protocol.MsgFullState, size)
+	binary.LittleEndian.PutUint16(buf[offset:offset+2], uint16(len(players)))
+	offset += 2
+
+	for i, p := range players {
+		offset += g.putRosterEntry(buf, offset, p, profiles[i])
+	}
+	for _, record := range current {
+		offset += copy(buf[offset:], record.bytes)
+	}
+	return buf
+}
@@ -0,0 +1,201 @@
+package gamebase
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+	"sync"
+
+	"game/protocol"
+)
+
+type PlayerScore struct {
+	PlayerID  int     `json:"player_id"`
+	Kills     int     `json:"kills"`
+	Deaths    int     `json:"deaths"`
+	Assists   int     `json:"assists"`
+	Objective int     `json:"objective"`
+	TimeAlive float64 `json:"time_alive"` // seconds
+	Ping      int     `json:"ping"`       // round trip in milliseconds
+
+	alive bool
+}
+
+// Scoreboard tracks per-player stats for the current match.
+// It is safe for concurrent use: the game loop writes to it while
+// HTTP handlers read snapshots.
+type Scoreboard struct {
+	mu     sync.RWMutex
+	scores map[int]*PlayerScore
+	dirty  bool
+}
+
+func NewScoreboard() *Scoreboard {
+	return &Scoreboard{
+		scores: make(map[int]*PlayerScore),
+	}
+}
+
+func (s *Scoreboard) AddPlayer(playerID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.scores[playerID] = &PlayerScore{PlayerID: playerID, alive: true}
+	s.dirty = true
+}
+
+func (s *Scoreboard) RemovePlayer(playerID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.scores, playerID)
+	s.dirty = true
+}
+
+// RecordKill credits killerID with a kill, victimID with a death and every
+// assister with an assist. A negative killerID means the victim died to
+// the environment or to themselves.
+func (s *Scoreboard) RecordKill(killerID, victimID int, assistIDs []int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if victim, ok := s.scores[victimID]; ok {
+		victim.Deaths++
+		victim.alive = false
+	}
+
+	if killer, ok := s.scores[killerID]; ok && killerID != victimID {
+		killer.Kills++
+	}
+
+	for _, id := range assistIDs {
+		if id == killerID || id == victimID {
+			continue
+		}
+		if assister, ok := s.scores[id]; ok {
+			assister.Assists++
+		}
+	}
+
+	s.dirty = true
+}
+
+func (s *Scoreboard) RecordRespawn(playerID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if score, ok := s.scores[playerID]; ok {
+		score.alive = true
+	}
+}
+
+func (s *Scoreboard) AddObjective(playerID, points int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if score, ok := s.scores[playerID]; ok {
+		score.Objective += points
+		s.dirty = true
+	}
+}
+
+// Tick accumulates time alive. It does not mark the board dirty,
+// time alive is only pushed with the periodic update.
+func (s *Scoreboard) Tick(delta float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, score := range s.scores {
+		if score.alive {
+			score.TimeAlive += delta
+		}
+	}
+}
+
+// SetPing records a player's latency. Like time alive it does not mark
+// the board dirty and is only pushed with the periodic update.
+func (s *Scoreboard) SetPing(playerID, ms int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if score, ok := s.scores[playerID]; ok {
+		score.Ping = ms
+	}
+}
+
+// Reset zeroes every stat but keeps the current players on the board.
+func (s *Scoreboard) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id := range s.scores {
+		s.scores[id] = &PlayerScore{PlayerID: id, Ping: s.scores[id].Ping, alive: true}
+	}
+	s.dirty = true
+}
+
+// TakeDirty reports whether the board changed since the last call
+// and clears the flag.
+func (s *Scoreboard) TakeDirty() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dirty := s.dirty
+	s.dirty = false
+	return dirty
+}
+
+// Snapshot returns a copy of the board sorted by kills, then objective.
+func (s *Scoreboard) Snapshot() []PlayerScore {
+	s.mu.RLock()
+	out := make([]PlayerScore, 0, len(s.scores))
+	for _, score := range s.scores {
+		out = append(out, *score)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Kills != out[j].Kills {
+			return out[i].Kills > out[j].Kills
+		}
+		if out[i].Objective != out[j].Objective {
+			return out[i].Objective > out[j].Objective
+		}
+		return out[i].PlayerID < out[j].PlayerID
+	})
+
+	return out
+}
+
+//Serializable
+
+const scoreRecordSize = 4*6 + 2
+
+// Per record: [4 id][4 kills][4 deaths][4 assists][4 objective][4 time alive f32][2 ping ms]
+func encodeScores(scores []PlayerScore, buf []byte, start int) int {
+	offset := start
+	for _, score := range scores {
+		binary.LittleEndian.PutUint32(buf[offset:offset+4], uint32(score.PlayerID))
+		binary.LittleEndian.PutUint32(buf[offset+4:offset+8], uint32(score.Kills))
+		binary.LittleEndian.PutUint32(buf[offset+8:offset+12], uint32(score.Deaths))
+		binary.LittleEndian.PutUint32(buf[offset+12:offset+16], uint32(score.Assists))
+		binary.LittleEndian.PutUint32(buf[offset+16:offset+20], uint32(int32(score.Objective)))
+		binary.LittleEndian.PutUint32(buf[offset+20:offset+24], math.Float32bits(float32(score.TimeAlive)))
+		binary.LittleEndian.PutUint16(buf[offset+24:offset+26], uint16(min(score.Ping, math.MaxUint16)))
+		offset += scoreRecordSize
+	}
+	return offset - start
+}
+
+func (g *Game) scoreboardMessage() []byte {
+	scores := g.Scoreboard.Snapshot()
+
+	buf, offset := newMessage(protocol.MsgScoreboard, len(scores)*scoreRecordSize)
+	encodeScores(scores, buf, offset)
+
+	return buf
+}
+
+func (g *Game) broadcastScoreboard() {
+	g.broadcast(g.scoreboardMessage())
+}
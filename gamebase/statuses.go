@@ -0,0 +1,147 @@
+package gamebase
+
+import (
+	"encoding/binary"
+	"time"
+
+	"game/core"
+	"game/player"
+	"game/protocol"
+)
+
+const (
+	StatusSpeedBoost uint16 = iota + 1
+	StatusSlow
+	StatusStun
+	StatusDash
+)
+
+const (
+	TagBuff         = "buff"
+	TagDebuff       = "debuff"
+	TagCrowdControl = "crowd_control"
+)
+
+// statusBase holds the bookkeeping shared by every status,
+// statuses embed it and override the hooks they need.
+type statusBase struct {
+	id        uint16
+	tags      []string
+	duration  int
+	stackRule core.StackRule
+	maxStacks int
+}
+
+func (s *statusBase) StatusID() uint16                         { return s.id }
+func (s *statusBase) Tags() []string                           { return s.tags }
+func (s *statusBase) Duration() int                            { return s.duration }
+func (s *statusBase) StackRule() core.StackRule                { return s.stackRule }
+func (s *statusBase) MaxStacks() int                           { return s.maxStacks }
+func (s *statusBase) OnApply(obj core.GameObject, stacks int)  {}
+func (s *statusBase) OnTick(obj core.GameObject, stacks int)   {}
+func (s *statusBase) OnExpire(obj core.GameObject, stacks int) {}
+
+type SpeedBoostStatus struct {
+	statusBase
+	factor float32
+}
+
+func NewSpeedBoost(factor float32, ticks int) *SpeedBoostStatus {
+	return &SpeedBoostStatus{
+		statusBase: statusBase{id: StatusSpeedBoost, tags: []string{TagBuff}, duration: ticks, stackRule: core.StackRefresh},
+		factor:     factor,
+	}
+}
+
+func (s *SpeedBoostStatus) SpeedFactor(stacks int) float32 {
+	return s.factor
+}
+
+// SlowStatus stacks, every stack multiplies speed by factor.
+type SlowStatus struct {
+	statusBase
+	factor float32
+}
+
+func NewSlow(factor float32, ticks, maxStacks int) *SlowStatus {
+	return &SlowStatus{
+		statusBase: statusBase{id: StatusSlow, tags: []string{TagDebuff}, duration: ticks, stackRule: core.StackCount, maxStacks: maxStacks},
+		factor:     factor,
+	}
+}
+
+func (s *SlowStatus) SpeedFactor(stacks int) float32 {
+	factor := float32(1)
+	for range stacks {
+		factor *= s.factor
+	}
+	return factor
+}
+
+// StunStatus cannot be refreshed while active, so chained stuns cannot lock a player down.
+type StunStatus struct {
+	statusBase
+}
+
+func NewStun(ticks int) *StunStatus {
+	return &StunStatus{
+		statusBase: statusBase{id: StatusStun, tags: []string{TagDebuff, TagCrowdControl}, duration: ticks, stackRule: core.StackUnique},
+	}
+}
+
+func (s *StunStatus) BlocksMovement() bool {
+	return true
+}
+
+func (s *StunStatus) OnApply(obj core.GameObject, stacks int) {
+	if physics, IsPhysics := obj.(core.PhysicsObject); IsPhysics {
+		physics.SetVelocity(&core.DirStop)
+	}
+}
+
+// ApplyStatus queues s on the target through the engine.
+func (g *Game) ApplyStatus(sourceID, targetID int, s core.StatusEffect) {
+	g.queueEffects(sourceID, map[int][]core.IEffect{
+		targetID: {&core.ApplyStatusEffect{Status: s}},
+	})
+}
+
+func (g *Game) Dispel(sourceID, targetID int, tag string) {
+	g.queueEffects(sourceID, map[int][]core.IEffect{
+		targetID: {&core.DispelEffect{Tag: tag}},
+	})
+}
+
+func (g *Game) ticks(d time.Duration) int {
+	return int(g.Match.durationToTicks(d))
+}
+
+func (g *Game) broadcastStatusChanges() {
+	for _, p := range g.Players() {
+		if p.StatusesChanged() {
+			g.broadcastAbout(p, g.statusMessage(p))
+		}
+	}
+}
+
+// Payload: [4 bytes player][1 byte count]
+// then per status [2 bytes status id][1 byte stacks][4 bytes remaining ms]
+func (g *Game) statusMessage(p *player.Player) []byte {
+	statuses := p.ActiveStatuses()
+
+	buf, offset := newMessage(protocol.MsgStatusEffects, 5+7*len(statuses))
+	binary.LittleEndian.PutUint32(buf[offset:offset+4], uint32(p.ID()))
+	buf[offset+4] = byte(len(statuses))
+	offset += 5
+
+	for _, s := range statuses {
+		remaining := g.Match.ticksToDuration(uint64(max(0, s.Remaining)))
+
+		binary.LittleEndian.PutUint16(buf[offset:offset+2], s.Effect.StatusID())
+		buf[offset+2] = byte(min(s.Stacks, 255))
+		binary.LittleEndian.PutUint32(buf[offset+3:offset+7], uint32(remaining.Milliseconds()))
+		offset += 7
+	}
+
+	return buf
+}
@@ -0,0 +1,236 @@
+package gamebase
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+
+	"game/core"
+	"game/player"
+	"game/protocol"
+)
+
+type TeamID = uint8
+
+// TeamNone marks a free agent, team IDs start at 1.
+const TeamNone TeamID = 0
+
+// MaxTeams keeps team IDs within 4 bits, modes asking for more are capped.
+const MaxTeams = 15
+
+var (
+	ErrNoTeams        = errors.New("mode has no teams")
+	ErrInvalidTeam    = errors.New("invalid team")
+	ErrSameTeam       = errors.New("already on that team")
+	ErrSwitchCooldown = errors.New("team switch on cooldown")
+	ErrSwitchLimit    = errors.New("team switch limit reached")
+	ErrTeamImbalance  = errors.New("switch would unbalance teams")
+)
+
+type TeamRules struct {
+	MaxImbalance   int    // largest allowed size difference after a manual switch
+	SwitchCooldown uint64 // ticks between manual switches of one player
+	MaxSwitches    int    // manual switches per player per match, 0 for unlimited
+}
+
+// Teams keeps team membership and team scores.
+// The player's own team field mirrors this for the wire format.
+type Teams struct {
+	mu sync.RWMutex
+
+	count   int
+	rules   TeamRules
+	members map[int]TeamID
+	scores  map[TeamID]int
+
+	lastSwitch map[int]uint64
+	switches   map[int]int
+}
+
+func NewTeams(count int, rules TeamRules) *Teams {
+	return &Teams{
+		count:      min(count, MaxTeams),
+		rules:      rules,
+		members:    make(map[int]TeamID),
+		scores:     make(map[TeamID]int),
+		lastSwitch: make(map[int]uint64),
+		switches:   make(map[int]int),
+	}
+}
+
+func (t *Teams) SetRules(rules TeamRules) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rules = rules
+}
+
+func (t *Teams) Count() int {
+	return t.count
+}
+
+// Assign auto-balances playerID onto the smallest team.
+// Ties go to the team with the lower score, then the lower ID.
+func (t *Teams) Assign(playerID int) TeamID {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.count == 0 {
+		return TeamNone
+	}
+
+	sizes := t.sizes()
+	best := TeamID(1)
+	for team := TeamID(2); int(team) <= t.count; team++ {
+		if sizes[team] < sizes[best] || (sizes[team] == sizes[best] && t.scores[team] < t.scores[best]) {
+			best = team
+		}
+	}
+
+	t.members[playerID] = best
+	return best
+}
+
+// Switch moves playerID to team if the switch rules allow it.
+func (t *Teams) Switch(playerID int, team TeamID, tick uint64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.count == 0 {
+		return ErrNoTeams
+	}
+	if team == TeamNone || int(team) > t.count {
+		return ErrInvalidTeam
+	}
+
+	current := t.members[playerID]
+	if current == team {
+		return ErrSameTeam
+	}
+
+	if last, ok := t.lastSwitch[playerID]; ok && tick-last < t.rules.SwitchCooldown {
+		return ErrSwitchCooldown
+	}
+	if t.rules.MaxSwitches > 0 && t.switches[playerID] >= t.rules.MaxSwitches {
+		return ErrSwitchLimit
+	}
+
+	sizes := t.sizes()
+	sizes[current]--
+	sizes[team]++
+	for other := TeamID(1); int(other) <= t.count; other++ {
+		if sizes[team]-sizes[other] > t.rules.MaxImbalance {
+			return ErrTeamImbalance
+		}
+	}
+
+	t.members[playerID] = team
+	t.lastSwitch[playerID] = tick
+	t.switches[playerID]++
+	return nil
+}
+
+func (t *Teams) Remove(playerID int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.members, playerID)
+	delete(t.lastSwitch, playerID)
+	delete(t.switches, playerID)
+}
+
+func (t *Teams) TeamOf(playerID int) TeamID {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.members[playerID]
+}
+
+// SameTeam reports whether two distinct players are teammates.
+// Free agents are never on the same team.
+func (t *Teams) SameTeam(a, b int) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	teamA, teamB := t.members[a], t.members[b]
+	return a != b && teamA != TeamNone && teamA == teamB
+}
+
+func (t *Teams) AddScore(team TeamID, points int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.scores[team] += points
+}
+
+// Scores returns the score of every team indexed by TeamID, index 0 unused.
+func (t *Teams) Scores() []int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	out := make([]int, t.count+1)
+	for team, score := range t.scores {
+		if int(team) <= t.count {
+			out[team] = score
+		}
+	}
+	return out
+}
+
+// Reset clears scores and switch limits for a new match, membership is kept.
+func (t *Teams) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.scores = make(map[TeamID]int)
+	t.lastSwitch = make(map[int]uint64)
+	t.switches = make(map[int]int)
+}
+
+// sizes must be called with mu held.
+func (t *Teams) sizes() map[TeamID]int {
+	sizes := make(map[TeamID]int, t.count)
+	for _, team := range t.members {
+		sizes[team]++
+	}
+	return sizes
+}
+
+// SwitchTeam moves p to team and tells everyone about it.
+func (g *Game) SwitchTeam(p *player.Player, team TeamID) error {
+	if err := g.Teams.Switch(p.ID(), team, g.Engine.Tick()); err != nil {
+		return err
+	}
+
+	g.queueEffects(p.ID(), map[int][]core.IEffect{
+		p.ID(): {&SetTeamEffect{Team: team}},
+	})
+
+	// Payload: [4 bytes player][1 byte team]
+	buf, offset := newMessage(protocol.MsgTeamChanged, 5)
+	binary.LittleEndian.PutUint32(buf[offset:offset+4], uint32(p.ID()))
+	buf[offset+4] = team
+	g.broadcast(buf)
+
+	return nil
+}
+
+func (g *Game) addTeamScore(team TeamID, points int) {
+	g.Teams.AddScore(team, points)
+	g.broadcastTeamScores()
+}
+
+// Payload: [1 byte team count][4 bytes score per team, team 1 first]
+func (g *Game) broadcastTeamScores() {
+	if g.Teams.Count() == 0 {
+		return
+	}
+
+	scores := g.Teams.Scores()[1:]
+	buf, offset := newMessage(protocol.MsgTeamScores, 1+4*len(scores))
+	buf[offset] = byte(len(scores))
+	offset++
+	for _, score := range scores {
+		binary.LittleEndian.PutUint32(buf[offset:offset+4], uint32(int32(score)))
+		offset += 4
+	}
+
+	g.broadcast(buf)
+}
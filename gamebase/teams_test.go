@@ -0,0 +1,96 @@
+package gamebase
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTeamsSwitch(t *testing.T) {
+	type step struct {
+		player int
+		team   TeamID
+		tick   uint64
+		want   error
+	}
+	tests := []struct {
+		name  string
+		count int
+		rules TeamRules
+		steps []step
+	}{
+		{"mode without teams", 0, TeamRules{}, []step{
+			{0, 1, 0, ErrNoTeams},
+		}},
+		{"invalid teams", 2, TeamRules{MaxImbalance: 4}, []step{
+			{0, TeamNone, 0, ErrInvalidTeam},
+			{0, 3, 0, ErrInvalidTeam},
+		}},
+		{"already on the team", 2, TeamRules{MaxImbalance: 4}, []step{
+			{0, 1, 0, ErrSameTeam},
+		}},
+		{"imbalance", 2, TeamRules{MaxImbalance: 1}, []step{
+			{0, 2, 0, ErrTeamImbalance},
+		}},
+		{"imbalance within limit", 2, TeamRules{MaxImbalance: 2}, []step{
+			{0, 2, 0, nil},
+			{2, 2, 0, ErrTeamImbalance},
+		}},
+		{"cooldown", 2, TeamRules{MaxImbalance: 4, SwitchCooldown: 10}, []step{
+			{0, 2, 0, nil},
+			{0, 1, 9, ErrSwitchCooldown},
+			{1, 1, 9, nil},
+			{0, 1, 10, nil},
+		}},
+		{"switch limit", 2, TeamRules{MaxImbalance: 4, MaxSwitches: 1}, []step{
+			{0, 2, 0, nil},
+			{0, 1, 100, ErrSwitchLimit},
+		}},
+		{"capped team count", MaxTeams + 1, TeamRules{MaxImbalance: 4}, []step{
+			{0, MaxTeams, 0, nil},
+			{0, MaxTeams + 1, 0, ErrInvalidTeam},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			teams := NewTeams(tt.count, tt.rules)
+			// Players 0 and 2 land on team 1, players 1 and 3 on team 2
+			for id := range 4 {
+				teams.Assign(id)
+			}
+
+			for i, s := range tt.steps {
+				before := teams.TeamOf(s.player)
+				err := teams.Switch(s.player, s.team, s.tick)
+				if !errors.Is(err, s.want) {
+					t.Fatalf("step %d: err %v, want %v", i, err, s.want)
+				}
+
+				want := s.team
+				if err != nil {
+					want = before
+				}
+				if got := teams.TeamOf(s.player); got != want {
+					t.Fatalf("step %d: player on team %d, want %d", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestTeamsResetClearsSwitchLimits(t *testing.T) {
+	teams := NewTeams(2, TeamRules{MaxImbalance: 4, SwitchCooldown: 10, MaxSwitches: 1})
+	teams.Assign(0)
+	teams.Assign(1)
+
+	if err := teams.Switch(0, 2, 0); err != nil {
+		t.Fatal(err)
+	}
+	teams.Reset()
+	if err := teams.Switch(0, 1, 1); err != nil {
+		t.Fatalf("switch after reset: %v", err)
+	}
+	if got := teams.TeamOf(0); got != 1 {
+		t.Fatalf("player on team %d after reset, want 1", got)
+	}
+}
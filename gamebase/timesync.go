@@ -0,0 +1,38 @@
+package gamebase
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+
+	"game/player"
+	"game/protocol"
+)
+
+// HandleTimeSync answers a client's clock probe. With its own send and receive
+// times the client gets the round trip and the offset of the server clock, NTP style.
+// received is the Engine.Now reading taken when the probe arrived.
+func (g *Game) HandleTimeSync(p *player.Player, clientTime float64, received time.Duration) {
+	g.sendTo(p, g.timeSyncMessage(clientTime, received))
+}
+
+// Payload: [8 bytes client send time f64 ms, echoed][8 bytes server receive time f64 ms]
+// [8 bytes server send time f64 ms][4 bytes tick][8 bytes time that tick was simulated f64 ms].
+// Server times come from Engine.Now. The tick is repeated in the payload because
+// the header's may already be the next one.
+func (g *Game) timeSyncMessage(clientTime float64, received time.Duration) []byte {
+	tick, tickAt := g.Engine.LastTick()
+
+	buf, offset := newMessage(protocol.MsgTimeSync, 36)
+	binary.LittleEndian.PutUint64(buf[offset:offset+8], math.Float64bits(clientTime))
+	binary.LittleEndian.PutUint64(buf[offset+8:offset+16], math.Float64bits(millis(received)))
+	binary.LittleEndian.PutUint32(buf[offset+24:offset+28], uint32(tick))
+	binary.LittleEndian.PutUint64(buf[offset+28:offset+36], math.Float64bits(millis(tickAt)))
+	// The send time goes in last so it is as close to the write as we can get
+	binary.LittleEndian.PutUint64(buf[offset+16:offset+24], math.Float64bits(millis(g.Engine.Now())))
+	return buf
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -10,6 +10,45 @@ type Character interface {
 	core.ConcreteObject
 	GetSpeed() float32
 	Move(string)
+	MoveAnalog(core.Vector)
+	Facing() core.Vector
+	SetFacing(core.Vector)
+	BindAbility(slot int, a core.Ability)
+	UseAbility(slot int) error
+	AbilityStates() []core.AbilityState
+	State() core.CharacterState
+}
+
+type Damageable interface {
+	core.GameObject
+	Health() int
+	IsAlive() bool
+	TakeDamage(amount int) bool
+	Heal(amount int)
+	Revive()
+}
+
+type TeamMember interface {
+	core.GameObject
+	Team() uint8
+	SetTeam(uint8)
+}
+
+// Tunable objects take their base stats from the game rules.
+type Tunable interface {
+	core.GameObject
+	SetBaseSpeed(pxps float32)
+	SetMaxHealth(maxHealth int)
+}
+
+type InputAcker interface {
+	core.GameObject
+	AckInput(seq uint32)
+}
+
+type Shieldable interface {
+	core.GameObject
+	AddShield(amount int)
 }
 
 // You can define other high-level concepts here
@@ -5,17 +5,25 @@ import (
 	"encoding/binary"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"os"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 	"html/template"
 
+	"game/config"
 	"game/middleware"
+	"game/outbox"
+	"game/protocol"
 	"game/player"     
 	"game/utils"      
 	"game/gamebase"
 	"game/core"
+	"game/spectator"
+	"game/transport"
 
 	"github.com/gorilla/sessions"
 	"github.com/gorilla/websocket"
@@ -24,6 +32,7 @@ import (
 
 type TemplateData struct {
 	GameState template.JS
+	Profiles  template.JS
 	PlayerID  int 
 	Token     string
 	Binary    []byte
@@ -34,12 +43,6 @@ type PendingConnection struct {
 	Expires  time.Time
 }
 
-const (
-	maxPlayers = 10
-	fixedTPS   = 30
-	targetFPS   = 120
-	playerBasePxPs = 800 )
-
 type GameHandler struct {
 	log *log.Logger
 
@@ -51,15 +54,43 @@ type GameHandler struct {
 	pendingTokens map[string]*PendingConnection
 
 	store *sessions.CookieStore
+
+	spectatorsMu    sync.RWMutex
+	spectators      map[int]*spectator.Spectator
+	nextSpectatorID int
+
+	sessionsMu     sync.Mutex
+	playerSessions map[int]*session // by player ID
+	resumeTokens   map[string]int
+
+	// server and room are fixed at startup, rules can be reloaded
+	server   config.Server
+	roomName string
+	room     config.Room
+	rulesMu  sync.RWMutex
+	rules    config.Rules
 }
 
-func NewGameHandler(l *log.Logger, s *sessions.CookieStore) *GameHandler {
+func NewGameHandler(l *log.Logger, s *sessions.CookieStore, cfg *config.Config, roomName string) *GameHandler {
+	room, rules, err := cfg.Room(roomName)
+	if err != nil {
+		l.Fatalln("Room config error:", err)
+	}
+
 	handler := &GameHandler{
 		log:      l,
 		upgrader: &websocket.Upgrader{}, 
 		store:    s,
 
 		pendingTokens: make(map[string]*PendingConnection),
+		spectators:    make(map[int]*spectator.Spectator),
+		playerSessions: make(map[int]*session),
+		resumeTokens:   make(map[string]int),
+
+		server:   cfg.Server,
+		roomName: roomName,
+		room:     room,
+		rules:    rules,
 	}
 
 	base := core.State{
@@ -74,9 +105,29 @@ func NewGameHandler(l *log.Logger, s *sessions.CookieStore) *GameHandler {
 		Players: make(map[string]*player.Player),
 	}
 
-	handler.game = gamebase.NewGame(&gameState,fixedTPS, targetFPS, maxPlayers ,l)
+	handler.game = gamebase.NewGame(&gameState, cfg.Server.FixedTPS, cfg.Server.TargetFPS, room.MaxPlayers, handler.matchConfig(rules), l)
+
+	mode, err := gamebase.NewMode(room.Mode)
+	if err != nil {
+		l.Fatalln("Game mode error:", err)
+	}
+	handler.game.SetMode(mode, handler.teamRules(rules))
+	handler.game.SetCombatTimers(rules.RespawnDelay.Duration, rules.AssistWindow.Duration)
+	handler.game.SetViewRadius(cfg.Server.ViewRadius)
+	handler.game.SetLagCompensation(cfg.Server.HistoryTicks, cfg.Server.MaxRewind.Duration)
+
+	arena, err := gamebase.ArenaByName(room.Map)
+	if err != nil {
+		l.Fatalln("Map error:", err)
+	}
+	handler.game.AddPickups(gamebase.DefaultPickups())
+	handler.game.SetArena(arena, room.FogOfWar)
+	handler.game.SetCompactSnapshots(cfg.Server.CompactSnapshots)
+	handler.game.SetFullStateInterval(cfg.Server.FullStateInterval.Duration)
 
 	handler.game.BroadcastFunc = handler.broadcastMessage
+	handler.game.SendFunc = handler.sendMessage
+	handler.game.SpectateFunc = handler.spectateMessage
 
 	handler.game.Start()
 
@@ -85,6 +136,67 @@ func NewGameHandler(l *log.Logger, s *sessions.CookieStore) *GameHandler {
 	return handler
 }
 
+func (g *GameHandler) matchConfig(rules config.Rules) gamebase.MatchConfig {
+	return gamebase.MatchConfig{
+		MinPlayers:    rules.MinPlayers,
+		Countdown:     rules.Countdown.Duration,
+		RoundDuration: rules.RoundDuration.Duration,
+		Overtime:      rules.Overtime.Duration,
+		Intermission:  rules.Intermission.Duration,
+	}
+}
+
+func (g *GameHandler) outboxConfig() outbox.Config {
+	return outbox.Config{
+		QueueSize:    g.server.SendQueueSize,
+		WriteTimeout: g.server.WriteTimeout.Duration,
+		MaxBacklog:   g.server.MaxSendBacklog.Duration,
+		PingInterval: g.server.PingInterval.Duration,
+		PongTimeout:  g.server.PongTimeout.Duration,
+	}
+}
+
+func (g *GameHandler) teamRules(rules config.Rules) gamebase.TeamRules {
+	return gamebase.TeamRules{
+		MaxImbalance:   rules.TeamMaxImbalance,
+		SwitchCooldown: uint64(math.Ceil(rules.TeamSwitchCooldown.Seconds() * g.server.FixedTPS)),
+		MaxSwitches:    rules.TeamMaxSwitches,
+	}
+}
+
+// Reload applies the rules of the handler's room from cfg while the game runs.
+// Server and room settings only take effect after a restart, except the view radius.
+func (g *GameHandler) Reload(cfg *config.Config) {
+	room, rules, err := cfg.Room(g.roomName)
+	if err != nil {
+		g.log.Println("Reload error:", err)
+		return
+	}
+	server := cfg.Server
+	server.ViewRadius = g.server.ViewRadius
+	if room != g.room || server != g.server {
+		g.log.Println("Server and room settings changed, restart to apply them")
+	}
+
+	g.rulesMu.Lock()
+	g.rules = rules
+	g.rulesMu.Unlock()
+
+	g.game.Match.SetConfig(g.matchConfig(rules))
+	g.game.Teams.SetRules(g.teamRules(rules))
+	g.game.SetCombatTimers(rules.RespawnDelay.Duration, rules.AssistWindow.Duration)
+	g.game.SetViewRadius(cfg.Server.ViewRadius)
+	g.game.SetPlayerStats(rules.PlayerSpeed, rules.MaxHealth)
+
+	g.log.Println("Reloaded rules for room", g.roomName)
+}
+
+func (g *GameHandler) currentRules() config.Rules {
+	g.rulesMu.RLock()
+	defer g.rulesMu.RUnlock()
+	return g.rules
+}
+
 func (g *GameHandler) Join(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(middleware.ContextPlayerID).(string)
 	if !ok {
@@ -92,7 +204,13 @@ func (g *GameHandler) Join(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if g.game.GetPlayerByUserID(userID) != nil {		
+	if existing := g.game.GetPlayerByUserID(userID); existing != nil {
+		// A reload during the grace period picks the same player back up
+		if g.detached(existing) {
+			g.renderGame(w, existing, g.pendingToken(existing.ID()))
+			return
+		}
+
 		utils.RenderMessage(w, utils.MessageData{
 
 			Type:     "error",
@@ -113,45 +231,83 @@ func (g *GameHandler) Join(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	profile, err := joinProfile(r, slot)
+	if err != nil {
+		renderProfileError(w, err)
+		return
+	}
+
+	token := g.pendingToken(slot)
+
+	playerLogger := log.New(os.Stdout, fmt.Sprintf("Player %d [%s]: ", slot, userID), log.LstdFlags)
+	rules := g.currentRules()
+	p := player.NewPlayer(slot, userID, 0, 0, rules.PlayerSpeed, playerLogger)
+	p.SetMaxHealth(rules.MaxHealth)
+	p.Revive()
+	p.SetProfile(profile)
+
+
+	if err := g.game.AddPlayer(p); err != nil {
+		g.tokensMu.Lock()
+		delete(g.pendingTokens, token)
+		g.tokensMu.Unlock()
+
+		renderProfileError(w, err)
+		return
+	}
+
+	g.renderGame(w, p, token)
+}
+
+// pendingToken lets the page about to be rendered open the websocket of playerID.
+func (g *GameHandler) pendingToken(playerID int) string {
 	token := utils.GenerateToken()
 
 	g.tokensMu.Lock()
-
 	g.pendingTokens[token] = &PendingConnection{
-		PlayerID: slot,
+		PlayerID: playerID,
 		Expires:  time.Now().Add(30 * time.Second),
 	}
-
 	g.tokensMu.Unlock()
 
+	return token
+}
 
-	playerLogger := log.New(os.Stdout, fmt.Sprintf("Player %d [%s]: ", slot, userID), log.LstdFlags)
-	p := player.NewPlayer(slot, userID, 0, 0, playerBasePxPs, nil, playerLogger)
-
-
-	g.game.AddPlayer(p) 
+func (g *GameHandler) renderGame(w http.ResponseWriter, p *player.Player, token string) {
+	visible := g.game.VisibleObjects(p)
+	visibleByID := make(map[int]core.GameObject, len(visible))
+
+	// Objects keep changing while the page is built, serialize them under the state lock
+	var combined, jsonBytes []byte
+	var err error
+	g.game.Engine.ReadState(func() {
+		totalSize := 0
+		for _, conc := range visible {
+			objSize := conc.Size()
+			totalSize += 4 + objSize
+			visibleByID[conc.ID()] = conc
+		}
 
+		combined = make([]byte, totalSize)
 
-	totalSize := 0
-	for _, conc := range g.game.State.Base.Objects {
-		objSize := conc.Size()
-		totalSize += 4 + objSize
-	}
+		offset := 0
+		for _, conc := range visible {
+			objSize := conc.Size()
 
-	combined := make([]byte, totalSize)
+			binary.LittleEndian.PutUint32(combined[offset:offset+4], uint32(objSize))
+			offset += 4
 
-	offset := 0
-	for _, conc := range g.game.State.Base.Objects {
-		objSize := conc.Size()
+			offset += conc.ToBytes(combined, offset)
+		}
 
-		binary.LittleEndian.PutUint32(combined[offset:offset+4], uint32(objSize))
-		offset += 4
+		jsonBytes, err = json.Marshal(visibleByID)
+	})
 
-		offset += conc.ToBytes(combined, offset)
+	if err != nil {
+		panic(err)
 	}
-	
 
-	jsonBytes, err := json.Marshal(g.game.State.Base.Objects)
+	profileBytes, err := json.Marshal(g.game.Profiles())
 
 	if err != nil {
 		panic(err)
@@ -159,7 +315,8 @@ func (g *GameHandler) Join(w http.ResponseWriter, r *http.Request) {
 
 	templateData := TemplateData{
 		GameState: template.JS(jsonBytes),
-		PlayerID: slot,
+		Profiles: template.JS(profileBytes),
+		PlayerID: p.ID(),
 		Token: token,
 		Binary: combined,
 	}
@@ -182,9 +339,37 @@ func (g *GameHandler) Join(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// joinProfile reads the profile chosen before joining from the query,
+// players that did not pick a name get a default one.
+func joinProfile(r *http.Request, slot int) (player.Profile, error) {
+	query := r.URL.Query()
+	if query.Get("name") == "" {
+		return gamebase.DefaultProfile(slot), nil
+	}
+
+	color, _ := strconv.Atoi(query.Get("color"))
+	skin, _ := strconv.Atoi(query.Get("skin"))
+	avatar, _ := strconv.Atoi(query.Get("avatar"))
+
+	return gamebase.NewProfile(query.Get("name"), color, skin, avatar)
+}
+
+func renderProfileError(w http.ResponseWriter, err error) {
+	utils.RenderMessage(w, utils.MessageData{
+		Type:     "error",
+		Title:    "Invalid Profile",
+		Message:  "Could not join with this profile: " + err.Error(),
+		Link:     "/",
+		LinkText: "Try Again",
+	})
+}
+
+// Match opens the websocket of a player, either with the token of the page
+// that was just rendered or with the resume token of a dropped connection.
 func (g *GameHandler) Match(w http.ResponseWriter, r *http.Request) {
 	token := r.URL.Query().Get("token")
-	if token == "" {
+	resume := r.URL.Query().Get("resume")
+	if token == "" && resume == "" {
 		http.Error(w, "Missing token", http.StatusBadRequest)
 		return
 	}
@@ -194,71 +379,228 @@ func (g *GameHandler) Match(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	resuming := resume != ""
+	var p *player.Player
+	if resuming {
+		var err error
+		if p, err = g.resumablePlayer(resume, userID); err != nil {
+			http.Error(w, "Cannot resume", http.StatusGone)
+			return
+		}
+	} else {
+		if _, ok := g.takePendingToken(token); !ok {
+			http.Error(w, "Invalid token", http.StatusForbidden)
+			return
+		}
+
+		if p = g.game.GetPlayerByUserID(userID); p == nil {
+			http.Error(w, "Player left", http.StatusGone)
+			return
+		}
+	}
+
+	conn, err := g.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		g.log.Println("WebSocket upgrade error:", err)
+		return
+	}
+
+	t := transport.NewWebSocket(conn)
+	if _, err := g.handshake(t); err != nil {
+		g.log.Println("Handshake error for player", p.ID(), ":", err)
+		// A failed resume or reload leaves the player to its grace period
+		if !resuming && !g.hasSession(p) {
+			g.game.RemovePlayer(p)
+		}
+		t.Close()
+		return
+	}
+
+	g.connect(p, t, resuming)
+}
+
+// takePendingToken consumes a token handed out by pendingToken.
+func (g *GameHandler) takePendingToken(token string) (*PendingConnection, bool) {
 	g.tokensMu.Lock()
 	pending, exists := g.pendingTokens[token]
-	delete(g.pendingTokens, token) 
+	delete(g.pendingTokens, token)
 	g.tokensMu.Unlock()
 
 	if !exists || time.Now().After(pending.Expires) {
-		http.Error(w, "Invalid token", http.StatusForbidden)
-		return
+		return nil, false
 	}
+	return pending, true
+}
 
-	conn, err := g.upgrader.Upgrade(w, r, nil)
+// connect makes t the connection of p, whose client passed the handshake,
+// and serves it until it ends.
+func (g *GameHandler) connect(p *player.Player, t transport.Transport, resuming bool) {
+	out, generation, resumed, err := g.attach(p, t, resuming)
 	if err != nil {
-		g.log.Println("WebSocket upgrade error:", err)
+		t.CloseWith(protocol.CloseSessionExpired, err.Error())
 		return
 	}
 
-	p := g.game.State.Players[userID]
+	if resumed {
+		g.game.ResumePlayer(p)
+		g.log.Println("User Resumed:", p.ID(), "UserID:", p.UserID())
+	} else {
+		g.game.OnPlayerConnected(p)
+		g.log.Println("User Joined:", p.ID(), "UserID:", p.UserID())
+	}
 
-	p.SetConn(conn)
+	g.handlePlayerConnection(p, out, generation)
+}
 
+type ScoreboardResponse struct {
+	Tick    uint64                 `json:"tick"`
+	Phase   string                 `json:"phase"`
+	Mode    string                 `json:"mode"`
+	Teams   []int                  `json:"teams,omitempty"` // index 0 is team 1
+	Players []gamebase.PlayerScore `json:"players"`
+}
 
-	g.log.Println("User Joined:", p.ID(), "UserID:", p.UserID())
+func (g *GameHandler) Scoreboard(w http.ResponseWriter, r *http.Request) {
+	resp := ScoreboardResponse{
+		Tick:    g.game.Engine.Tick(),
+		Phase:   g.game.Match.Phase().String(),
+		Mode:    g.game.Mode.Name(),
+		Players: g.game.Scoreboard.Snapshot(),
+	}
+	if g.game.Teams.Count() > 0 {
+		resp.Teams = g.game.Teams.Scores()[1:]
+	}
 
-	g.handlePlayerConnection(p)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		g.log.Println("Scoreboard encode error:", err)
+	}
 }
 
-func (g *GameHandler) handlePlayerConnection(p *player.Player) {
-	defer func() {
-		g.game.RemovePlayer(p) 
-		g.log.Println("User Left:", p.ID(), "UserID:", p.UserID())
-		err := p.Conn().Close()
+// passiveEvents are sent by clients on their own, they do not keep a player from idling.
+var passiveEvents = map[string]bool{
+	"viewport":           true,
+	"full_state_request": true,
+}
 
-		if err != nil {
-			g.log.Println("Error")
+// idleTimer closes out once idle_timeout passes without a reset and sets kicked,
+// it is nil when idle players are kept.
+func (g *GameHandler) idleTimer(p *player.Player, out *outbox.Outbox, kicked *atomic.Bool) *time.Timer {
+	timeout := g.server.IdleTimeout.Duration
+	if timeout <= 0 {
+		return nil
+	}
+	return time.AfterFunc(timeout, func() {
+		g.log.Println("Disconnecting idle player", p.ID())
+		kicked.Store(true)
+		out.CloseWith(protocol.CloseIdle, "idle for "+timeout.String())
+	})
+}
+
+// handlePlayerConnection reads from one connection of p until it ends.
+// Players kicked for idling leave at once, others get the reconnect grace period.
+func (g *GameHandler) handlePlayerConnection(p *player.Player, out *outbox.Outbox, generation int) {
+	var kicked atomic.Bool
+	idle := g.idleTimer(p, out, &kicked)
+	active := func() {
+		if idle != nil {
+			idle.Reset(g.server.IdleTimeout.Duration)
+		}
+	}
+
+	defer func() {
+		if idle != nil {
+			idle.Stop()
 		}
+		out.Close()
+		g.detach(p, generation, !kicked.Load())
 
+		st := out.Transport().Stats()
+		g.log.Println("Connection Closed:", p.ID(), "Sent:", st.MessagesSent, "messages", st.BytesSent, "bytes",
+			"Received:", st.MessagesReceived, "messages", st.BytesReceived, "bytes")
 	}()
 
 	for {
-		_, msg, err := p.Conn().ReadMessage()
+		kind, msg, err := out.Transport().Receive()
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+			if transport.IsUnexpectedClose(err) {
 				g.log.Println("Read error:", err)
 			}
 			return 
 		}
 
+		// Binary messages carry input, text messages the JSON events still in use during migration
+		if kind == transport.Binary {
+			if clientMsg, err := protocol.ClientMsgTypeOf(msg); err == nil && clientMsg == protocol.ClientInput {
+				active()
+			}
+			g.handleBinaryMessage(msg, p)
+			continue
+		}
+
 		var clientEv core.ClientEvent
 		if err := json.Unmarshal(msg, &clientEv); err != nil {
 			g.log.Println("JSON unmarshal error for client event:", err)
 			continue
 		}
+		if !passiveEvents[clientEv.Type] {
+			active()
+		}
 
 		g.game.HandleInputEvent(&clientEv,p)
 
 	}
 }
 
-func (g *GameHandler) broadcastMessage(bytes []byte) {
+func (g *GameHandler) handleBinaryMessage(msg []byte, p *player.Player) {
+	received := g.game.Engine.Now()
+	clientMsg, err := protocol.ClientMsgTypeOf(msg)
+	if err != nil {
+		g.log.Println("Binary message error from player", p.ID(), ":", err)
+		return
+	}
+
+	switch clientMsg {
+	case protocol.ClientInput:
+		in, err := protocol.DecodeInput(msg)
+		if err != nil {
+			g.log.Println("Invalid input from player", p.ID(), ":", err)
+			return
+		}
+		g.game.HandleInput(in, p)
+	case protocol.ClientSnapshotAck:
+		tick, err := protocol.DecodeSnapshotAck(msg)
+		if err != nil {
+			g.log.Println("Invalid snapshot ack from player", p.ID(), ":", err)
+			return
+		}
+		g.game.AckSnapshot(p, tick)
+	case protocol.ClientTimeSync:
+		clientTime, err := protocol.DecodeTimeSync(msg)
+		if err != nil {
+			g.log.Println("Invalid time sync from player", p.ID(), ":", err)
+			return
+		}
+		g.game.HandleTimeSync(p, clientTime, received)
+	default:
+		g.log.Println("Unknown binary message", clientMsg, "from player", p.ID())
+	}
+}
 
-	for _, p := range g.game.State.Players {
-		if p != nil && p.Conn() != nil {
+func (g *GameHandler) broadcastMessage(bytes []byte) {
+	for _, p := range g.game.Players() {
+		if p.Transport() != nil {
 			p.Notify(bytes)
 		}
 	}
+
+	g.spectateMessage(bytes)
+}
+
+func (g *GameHandler) sendMessage(p *player.Player, bytes []byte) {
+	if p != nil && p.Transport() != nil {
+		p.Notify(bytes)
+	}
 }
 
 func (g *GameHandler) StartTokenCleanup() {
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"game/core"
+	"game/protocol"
+	"game/transport"
+)
+
+const handshakeTimeout = 5 * time.Second
+
+var ErrNoHello = errors.New("first message was not a hello")
+
+// handshake waits for the client's hello {version} and answers with a welcome.
+// Clients speaking another version are closed with protocol.CloseVersionMismatch.
+// The hello is returned for clients that send more than the version.
+func (g *GameHandler) handshake(t transport.Transport) (core.ClientEvent, error) {
+	var hello core.ClientEvent
+	if err := t.SetReadDeadline(time.Now().Add(handshakeTimeout)); err != nil {
+		return hello, err
+	}
+	defer t.SetReadDeadline(time.Time{})
+
+	_, msg, err := t.Receive()
+	if err != nil {
+		return hello, err
+	}
+
+	if err := json.Unmarshal(msg, &hello); err != nil || hello.Type != "hello" {
+		return hello, ErrNoHello
+	}
+
+	version, _ := hello.Data["version"].(float64)
+	if version != float64(protocol.Version) {
+		reason := fmt.Sprintf("protocol version %d required, client speaks %v", protocol.Version, hello.Data["version"])
+		_ = t.CloseWith(protocol.CloseVersionMismatch, reason)
+		return hello, fmt.Errorf("%w: %v", protocol.ErrVersion, hello.Data["version"])
+	}
+
+	if err := t.SetWriteDeadline(time.Now().Add(handshakeTimeout)); err != nil {
+		return hello, err
+	}
+	return hello, t.Send(transport.Binary, g.welcomeMessage())
+}
+
+// Welcome flags
+const welcomeCompactSnapshots = 1
+
+// Payload: [1 byte protocol version][2 bytes fixed ticks per second][1 byte flags]
+// With compact snapshots on it is followed by how positions are quantized:
+// [4 bytes max x f32][4 bytes max y f32][1 byte x bits][1 byte y bits], the minimum is 0.
+func (g *GameHandler) welcomeMessage() []byte {
+	x, y, compact := g.game.CompactQuantizers()
+
+	size := 4
+	if compact {
+		size += 10
+	}
+
+	buf, offset := protocol.New(protocol.MsgWelcome, g.game.Engine.Tick(), size)
+	buf[offset] = protocol.Version
+	binary.LittleEndian.PutUint16(buf[offset+1:offset+3], uint16(g.server.FixedTPS))
+	if compact {
+		buf[offset+3] = welcomeCompactSnapshots
+		binary.LittleEndian.PutUint32(buf[offset+4:offset+8], math.Float32bits(x.Max))
+		binary.LittleEndian.PutUint32(buf[offset+8:offset+12], math.Float32bits(y.Max))
+		buf[offset+12] = byte(x.Bits)
+		buf[offset+13] = byte(y.Bits)
+	}
+	return buf
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"game/config"
+	"game/player"
+	"game/protocol"
+	"game/transport"
+)
+
+// newTestHandler runs a game for one room with no heartbeats and the given grace period.
+func newTestHandler(t *testing.T, grace time.Duration) *GameHandler {
+	t.Helper()
+
+	cfg := &config.Config{
+		Server: config.Server{
+			FixedTPS:       200,
+			TargetFPS:      60,
+			ViewRadius:     1000,
+			SendQueueSize:  64,
+			WriteTimeout:   config.Duration{Duration: time.Second},
+			MaxSendBacklog: config.Duration{Duration: time.Second},
+			ReconnectGrace: config.Duration{Duration: grace},
+		},
+		Rooms: map[string]config.Room{
+			"default": {Mode: "ffa", Map: "default", MaxPlayers: 10},
+		},
+		Modes: map[string]config.Rules{
+			"ffa": {PlayerSpeed: 800, MaxHealth: 100, MinPlayers: 2, RoundDuration: config.Duration{Duration: time.Minute}},
+		},
+	}
+	g := NewGameHandler(log.New(io.Discard, "", 0), nil, cfg, "default")
+	t.Cleanup(g.game.Shutdown)
+	return g
+}
+
+func addTestPlayer(t *testing.T, g *GameHandler, id int) *player.Player {
+	t.Helper()
+
+	p := player.NewPlayer(id, fmt.Sprint("user", id), 0, 0, 800, log.New(io.Discard, "", 0))
+	p.SetProfile(player.Profile{Name: fmt.Sprint("Player", id)})
+	if err := g.game.AddPlayer(p); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func sendHello(t *testing.T, client transport.Transport, data map[string]any) {
+	t.Helper()
+
+	msg, err := json.Marshal(map[string]any{"type": "hello", "data": data})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Send(transport.Text, msg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// receive returns the payload of the next message of type want, skipping others.
+func receive(t *testing.T, client transport.Transport, want protocol.MsgType) []byte {
+	t.Helper()
+
+	if err := client.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		kind, msg, err := client.Receive()
+		if err != nil {
+			t.Fatalf("waiting for %v: %v", want, err)
+		}
+		if kind != transport.Binary {
+			continue
+		}
+		if h, err := protocol.ParseHeader(msg); err == nil && h.Type == want {
+			return msg[protocol.HeaderSize:]
+		}
+	}
+}
+
+// closeCode returns the code the server closed client with.
+func closeCode(t *testing.T, client transport.Transport) int {
+	t.Helper()
+
+	if err := client.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		_, _, err := client.Receive()
+		var ce *transport.CloseError
+		if errors.As(err, &ce) {
+			return ce.Code
+		}
+		if err != nil {
+			t.Fatalf("connection ended with %v, want a close code", err)
+		}
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	g := newTestHandler(t, 0)
+
+	hello := func(typ, data string) string {
+		return fmt.Sprintf(`{"type":%q,"data":{%s}}`, typ, data)
+	}
+	version := fmt.Sprint(`"version":`, protocol.Version)
+
+	tests := []struct {
+		name  string
+		hello string
+		want  error
+	}{
+		{"current version", hello("hello", version), nil},
+		{"other version", hello("hello", fmt.Sprint(`"version":`, protocol.Version-1)), protocol.ErrVersion},
+		{"no version", hello("hello", ""), protocol.ErrVersion},
+		{"not a hello", hello("input_movement", version), ErrNoHello},
+		{"not json", `hello`, ErrNoHello},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := transport.Pipe()
+			defer server.Close()
+			if err := client.Send(transport.Text, []byte(tt.hello)); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := g.handshake(server); !errors.Is(err, tt.want) {
+				t.Fatalf("err %v, want %v", err, tt.want)
+			}
+
+			switch {
+			case tt.want == nil:
+				if welcome := receive(t, client, protocol.MsgWelcome); welcome[0] != protocol.Version {
+					t.Fatalf("welcome for version %d", welcome[0])
+				}
+			case errors.Is(tt.want, protocol.ErrVersion):
+				if code := closeCode(t, client); code != protocol.CloseVersionMismatch {
+					t.Fatalf("closed with %d, want %d", code, protocol.CloseVersionMismatch)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"errors"
+	"net"
+
+	"game/core"
+	"game/player"
+	"game/transport"
+)
+
+var ErrNoToken = errors.New("hello carries no valid token")
+
+// ServeTCP accepts native clients on ln until it fails. They speak the same
+// protocol as browsers over transport.TCP. Having no cookie, they name their
+// player in the hello: {version, token} with the token of a joined page,
+// or {version, resume} with a resume token.
+func (g *GameHandler) ServeTCP(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go g.serveNative(transport.NewTCP(conn))
+	}
+}
+
+func (g *GameHandler) serveNative(t transport.Transport) {
+	hello, err := g.handshake(t)
+	if err != nil {
+		g.log.Println("Handshake error for native client", t.RemoteAddr(), ":", err)
+		t.Close()
+		return
+	}
+
+	p, resuming, err := g.nativePlayer(hello)
+	if err != nil {
+		g.log.Println("Native client", t.RemoteAddr(), "refused:", err)
+		t.CloseWith(transport.ClosePolicyViolation, err.Error())
+		return
+	}
+
+	g.connect(p, t, resuming)
+}
+
+// nativePlayer returns the player a native client's hello names
+// and whether the client is resuming its session.
+func (g *GameHandler) nativePlayer(hello core.ClientEvent) (*player.Player, bool, error) {
+	if resume, _ := hello.Data["resume"].(string); resume != "" {
+		p, err := g.sessionPlayer(resume)
+		return p, true, err
+	}
+
+	token, _ := hello.Data["token"].(string)
+	pending, ok := g.takePendingToken(token)
+	if !ok {
+		return nil, false, ErrNoToken
+	}
+	p := g.game.GetPlayerByID(pending.PlayerID)
+	if p == nil {
+		return nil, false, ErrNoToken
+	}
+	return p, false, nil
+}
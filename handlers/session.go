@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"game/outbox"
+	"game/player"
+	"game/protocol"
+	"game/transport"
+	"game/utils"
+)
+
+var (
+	ErrUnknownSession = errors.New("unknown resume token")
+	ErrSessionExpired = errors.New("session expired")
+)
+
+// session follows a player across reconnects. While the player has no
+// connection the grace timer runs, when it fires the player is removed.
+type session struct {
+	player     *player.Player
+	token      string // resume token, replaced on every attach
+	generation int    // counts attaches, a connection may only detach its own
+	grace      *time.Timer
+}
+
+// attach makes t p's connection and hands the client a fresh resume token.
+// A resume needs p's session to still be there, a fresh join picks up the
+// session of a player reloading during its grace period. It reports whether
+// p had a session already.
+func (g *GameHandler) attach(p *player.Player, t transport.Transport, resuming bool) (*outbox.Outbox, int, bool, error) {
+	g.sessionsMu.Lock()
+	s, existed := g.playerSessions[p.ID()]
+	switch {
+	case existed && s.player != p, resuming && !existed:
+		g.sessionsMu.Unlock()
+		return nil, 0, false, ErrSessionExpired
+	case !existed:
+		s = &session{player: p}
+		g.playerSessions[p.ID()] = s
+	}
+	if s.grace != nil {
+		if !s.grace.Stop() {
+			g.sessionsMu.Unlock()
+			return nil, 0, false, ErrSessionExpired
+		}
+		s.grace = nil
+	}
+
+	delete(g.resumeTokens, s.token)
+	s.token = utils.GenerateToken()
+	g.resumeTokens[s.token] = p.ID()
+	s.generation++
+	generation, token := s.generation, s.token
+
+	// Attaching under the lock keeps two resumes of one player from interleaving
+	out := p.Attach(t, g.outboxConfig())
+	g.sessionsMu.Unlock()
+
+	out.Send(g.sessionMessage(token))
+	return out, generation, existed, nil
+}
+
+// detach runs when a connection of p ends. Unless keep is false or there is
+// no grace period p stays in the world, frozen, until it resumes or grace runs out.
+// Connections replaced by a resume are ignored.
+func (g *GameHandler) detach(p *player.Player, generation int, keep bool) {
+	g.sessionsMu.Lock()
+	s, ok := g.playerSessions[p.ID()]
+	if !ok || s.generation != generation {
+		g.sessionsMu.Unlock()
+		return
+	}
+
+	grace := g.server.ReconnectGrace.Duration
+	if !keep || grace <= 0 {
+		g.endSessionLocked(s)
+		g.sessionsMu.Unlock()
+		g.removePlayer(p)
+		return
+	}
+
+	s.grace = time.AfterFunc(grace, func() { g.expireSession(p, generation) })
+	g.sessionsMu.Unlock()
+
+	g.game.SuspendPlayer(p)
+	g.log.Println("User Disconnected:", p.ID(), "UserID:", p.UserID(), "holding for", grace)
+}
+
+func (g *GameHandler) expireSession(p *player.Player, generation int) {
+	g.sessionsMu.Lock()
+	s, ok := g.playerSessions[p.ID()]
+	if !ok || s.generation != generation || s.grace == nil {
+		g.sessionsMu.Unlock()
+		return
+	}
+	g.endSessionLocked(s)
+	g.sessionsMu.Unlock()
+
+	g.removePlayer(p)
+}
+
+func (g *GameHandler) endSessionLocked(s *session) {
+	delete(g.playerSessions, s.player.ID())
+	delete(g.resumeTokens, s.token)
+}
+
+func (g *GameHandler) removePlayer(p *player.Player) {
+	g.game.RemovePlayer(p)
+	g.log.Println("User Left:", p.ID(), "UserID:", p.UserID())
+}
+
+// resumablePlayer returns the player a resume token belongs to, if it is userID's.
+func (g *GameHandler) resumablePlayer(token, userID string) (*player.Player, error) {
+	p, err := g.sessionPlayer(token)
+	if err != nil {
+		return nil, err
+	}
+	if p.UserID() != userID {
+		return nil, ErrUnknownSession
+	}
+	return p, nil
+}
+
+// sessionPlayer returns the player a resume token belongs to.
+func (g *GameHandler) sessionPlayer(token string) (*player.Player, error) {
+	g.sessionsMu.Lock()
+	defer g.sessionsMu.Unlock()
+
+	id, ok := g.resumeTokens[token]
+	if !ok {
+		return nil, ErrUnknownSession
+	}
+	return g.playerSessions[id].player, nil
+}
+
+// detached reports whether p is waiting out its grace period.
+func (g *GameHandler) detached(p *player.Player) bool {
+	g.sessionsMu.Lock()
+	defer g.sessionsMu.Unlock()
+
+	s, ok := g.playerSessions[p.ID()]
+	return ok && s.grace != nil
+}
+
+func (g *GameHandler) hasSession(p *player.Player) bool {
+	g.sessionsMu.Lock()
+	defer g.sessionsMu.Unlock()
+
+	_, ok := g.playerSessions[p.ID()]
+	return ok
+}
+
+// Payload: [4 bytes reconnect grace ms][resume token]
+func (g *GameHandler) sessionMessage(token string) []byte {
+	buf, offset := protocol.New(protocol.MsgSession, g.game.Engine.Tick(), 4+len(token))
+	binary.LittleEndian.PutUint32(buf[offset:offset+4], uint32(g.server.ReconnectGrace.Milliseconds()))
+	copy(buf[offset+4:], token)
+	return buf
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"game/player"
+	"game/protocol"
+	"game/transport"
+)
+
+// nativeClient connects over a pipe with hello data added to the version,
+// and returns the client end and a channel closed when the server is done with it.
+func nativeClient(t *testing.T, g *GameHandler, data map[string]any) (*transport.PipeEnd, chan struct{}) {
+	t.Helper()
+
+	server, client := transport.Pipe()
+	done := make(chan struct{})
+	// Runs before the game shuts down, cleanups go in reverse
+	t.Cleanup(func() {
+		client.Close()
+		<-done
+	})
+	go func() {
+		g.serveNative(server)
+		close(done)
+	}()
+
+	data["version"] = protocol.Version
+	sendHello(t, client, data)
+	return client, done
+}
+
+// resumeToken reads the session message the server sends once a client is attached.
+func resumeToken(t *testing.T, client transport.Transport) string {
+	t.Helper()
+	return string(receive(t, client, protocol.MsgSession)[4:])
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func closed(done chan struct{}) func() bool {
+	return func() bool {
+		select {
+		case <-done:
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+func TestNativeSessionResume(t *testing.T) {
+	g := newTestHandler(t, time.Minute)
+	p := addTestPlayer(t, g, 0)
+
+	client, done := nativeClient(t, g, map[string]any{"token": g.pendingToken(p.ID())})
+	token := resumeToken(t, client)
+	if p.Transport() == nil {
+		t.Fatal("player has no transport after joining")
+	}
+
+	// The player is held through the grace period
+	client.Close()
+	waitFor(t, "the connection to end", closed(done))
+	if !g.detached(p) || g.game.GetPlayerByID(p.ID()) != p {
+		t.Fatal("disconnected player was not kept for its grace period")
+	}
+
+	client, _ = nativeClient(t, g, map[string]any{"resume": token})
+	newToken := resumeToken(t, client)
+	if newToken == token {
+		t.Fatal("resume token was not replaced")
+	}
+	if g.detached(p) {
+		t.Fatal("resumed player still detached")
+	}
+
+	// The replaced token is spent
+	stale, _ := nativeClient(t, g, map[string]any{"resume": token})
+	if code := closeCode(t, stale); code != transport.ClosePolicyViolation {
+		t.Fatalf("stale token closed with %d, want %d", code, transport.ClosePolicyViolation)
+	}
+}
+
+func TestNativeSessionExpires(t *testing.T) {
+	g := newTestHandler(t, 20*time.Millisecond)
+	p := addTestPlayer(t, g, 0)
+
+	client, done := nativeClient(t, g, map[string]any{"token": g.pendingToken(p.ID())})
+	token := resumeToken(t, client)
+
+	client.Close()
+	waitFor(t, "the connection to end", closed(done))
+	waitFor(t, "the grace period to run out", func() bool {
+		return !g.hasSession(p) && g.game.GetPlayerByID(p.ID()) == nil
+	})
+
+	late, _ := nativeClient(t, g, map[string]any{"resume": token})
+	if code := closeCode(t, late); code != transport.ClosePolicyViolation {
+		t.Fatalf("expired token closed with %d, want %d", code, transport.ClosePolicyViolation)
+	}
+}
+
+func TestNativeUnknownToken(t *testing.T) {
+	g := newTestHandler(t, time.Minute)
+	p := addTestPlayer(t, g, 0)
+
+	client, _ := nativeClient(t, g, map[string]any{"token": "not-a-token"})
+	if code := closeCode(t, client); code != transport.ClosePolicyViolation {
+		t.Fatalf("closed with %d, want %d", code, transport.ClosePolicyViolation)
+	}
+	if g.hasSession(p) {
+		t.Fatal("refused client got a session")
+	}
+}
+
+func TestAttachRefusesLostSessions(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(g *GameHandler, p *player.Player) *player.Player // returns the player attaching
+	}{
+		{"resume without a session", func(g *GameHandler, p *player.Player) *player.Player {
+			return p
+		}},
+		{"session of another player", func(g *GameHandler, p *player.Player) *player.Player {
+			g.playerSessions[p.ID()] = &session{player: p}
+			return player.NewPlayer(p.ID(), "someone else", 0, 0, 800, nil)
+		}},
+		{"grace period already over", func(g *GameHandler, p *player.Player) *player.Player {
+			// The timer fired but its callback has not removed the session yet
+			fired := make(chan struct{})
+			grace := time.AfterFunc(0, func() { close(fired) })
+			<-fired
+			g.playerSessions[p.ID()] = &session{player: p, grace: grace}
+			return p
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestHandler(t, time.Minute)
+			p := addTestPlayer(t, g, 0)
+			attaching := tt.setup(g, p)
+
+			server, client := transport.Pipe()
+			defer client.Close()
+			if _, _, _, err := g.attach(attaching, server, true); !errors.Is(err, ErrSessionExpired) {
+				t.Fatalf("err %v, want %v", err, ErrSessionExpired)
+			}
+			if attaching.Transport() != nil {
+				t.Fatal("refused player was given the transport")
+			}
+			if g.game.GetPlayerByID(p.ID()) != p {
+				t.Fatal("refused attach removed the player")
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"game/core"
+	"game/middleware"
+	"game/protocol"
+	"game/spectator"
+	"game/transport"
+)
+
+// Spectate upgrades to a websocket that receives the whole world without taking a player slot.
+func (g *GameHandler) Spectate(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.ContextPlayerID).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	g.spectatorsMu.Lock()
+	if len(g.spectators) >= g.server.MaxSpectators {
+		g.spectatorsMu.Unlock()
+		http.Error(w, "Maximum spectator capacity reached", http.StatusServiceUnavailable)
+		return
+	}
+	id := g.nextSpectatorID
+	g.nextSpectatorID++
+	g.spectatorsMu.Unlock()
+
+	conn, err := g.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		g.log.Println("WebSocket upgrade error:", err)
+		return
+	}
+
+	t := transport.NewWebSocket(conn)
+	if _, err := g.handshake(t); err != nil {
+		g.log.Println("Handshake error:", err)
+		t.Close()
+		return
+	}
+
+	spectatorLogger := log.New(os.Stdout, fmt.Sprintf("Spectator %d [%s]: ", id, userID), log.LstdFlags)
+	s := spectator.NewSpectator(id, userID, t, g.server.SpectatorDelay.Duration, g.outboxConfig(), spectatorLogger)
+
+	g.spectatorsMu.Lock()
+	if len(g.spectators) >= g.server.MaxSpectators {
+		g.spectatorsMu.Unlock()
+		s.Close()
+		return
+	}
+	// The snapshot is sent under the lock so no stream message can overtake it
+	for _, msg := range g.game.SpectatorSnapshot() {
+		s.Notify(msg)
+	}
+	g.spectators[id] = s
+	g.spectatorsMu.Unlock()
+
+	g.log.Println("Spectator Joined:", id, "UserID:", userID)
+
+	g.handleSpectatorConnection(s)
+}
+
+func (g *GameHandler) handleSpectatorConnection(s *spectator.Spectator) {
+	defer func() {
+		g.spectatorsMu.Lock()
+		delete(g.spectators, s.ID())
+		g.spectatorsMu.Unlock()
+
+		g.log.Println("Spectator Left:", s.ID(), "UserID:", s.UserID())
+		s.Close()
+	}()
+
+	for {
+		_, msg, err := s.Transport().Receive()
+		if err != nil {
+			if transport.IsUnexpectedClose(err) {
+				g.log.Println("Read error:", err)
+			}
+			return
+		}
+
+		var clientEv core.ClientEvent
+		if err := json.Unmarshal(msg, &clientEv); err != nil {
+			g.log.Println("JSON unmarshal error for spectator event:", err)
+			continue
+		}
+
+		switch clientEv.Type {
+		case "spectate_follow":
+			playerID, ok := clientEv.Data["player_id"].(float64)
+			if !ok || g.game.GetPlayerByID(int(playerID)) == nil {
+				g.log.Println("Invalid follow target from spectator", s.ID())
+				continue
+			}
+			s.Follow(int(playerID))
+		case "spectate_free":
+			s.Follow(spectator.FreeCam)
+		default:
+			continue
+		}
+
+		s.Notify(g.spectateTargetMessage(s.Following()))
+	}
+}
+
+// Payload: [4 bytes followed player id, -1 for free cam]
+func (g *GameHandler) spectateTargetMessage(target int) []byte {
+	buf, offset := protocol.New(protocol.MsgSpectateTarget, g.game.Engine.Tick(), 4)
+	binary.LittleEndian.PutUint32(buf[offset:offset+4], uint32(int32(target)))
+	return buf
+}
+
+func (g *GameHandler) spectateMessage(bytes []byte) {
+	g.spectatorsMu.RLock()
+	defer g.spectatorsMu.RUnlock()
+
+	for _, s := range g.spectators {
+		s.Notify(bytes)
+	}
+}
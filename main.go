@@ -3,13 +3,17 @@ package main
 
 import (
 	"fmt"
+	"net"
 	"net/http"
 	_ "net/http/pprof"
 	"log"
+	"game/config"
 	"game/handlers"
 	"game/middleware"
 	"github.com/gorilla/sessions"
 	"os"
+	"os/signal"
+	"syscall"
 	//"time"
 	//"runtime"
 )
@@ -61,9 +65,42 @@ func main() {
 	http.HandleFunc("/error/duplicate", eh.Duplicate)
 	http.HandleFunc("/error/unauth", eh.UnAuthenticated)
 
+	//Game Configuration
+	configPath := envOr("GAME_CONFIG", "config.json")
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		log.Fatalln("Config error:", err)
+	}
+
 	//Game WebSocket Service
 	l = log.New(os.Stdout, "GameHandler: ", log.LstdFlags)
-	gh := handlers.NewGameHandler(l,store)
+	gh := handlers.NewGameHandler(l, store, cfg, envOr("GAME_ROOM", "default"))
+
+	// SIGHUP reloads the rules that are safe to change live
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			cfg, err := config.Load(configPath)
+			if err != nil {
+				l.Println("Config reload error:", err)
+				continue
+			}
+			gh.Reload(cfg)
+		}
+	}()
+	// Native clients skip the websocket and speak length prefixed frames
+	if cfg.Server.TCPAddr != "" {
+		ln, err := net.Listen("tcp", cfg.Server.TCPAddr)
+		if err != nil {
+			log.Fatalln("TCP listen error:", err)
+		}
+		fmt.Println("Native clients at", ln.Addr())
+		go func() {
+			l.Println("TCP server stopped:", gh.ServeTCP(ln))
+		}()
+	}
+
 	http.HandleFunc("/game", middleware.Chain(
 		gh.Match,
 		middleware.Logging(),
@@ -71,6 +108,20 @@ func main() {
 		middleware.Method("GET"),
 	))
 
+	http.HandleFunc("/spectate", middleware.Chain(
+		gh.Spectate,
+		middleware.Logging(),
+		authService.AuthMiddleware(),
+		middleware.Method("GET"),
+	))
+
+	http.HandleFunc("/scoreboard", middleware.Chain(
+		gh.Scoreboard,
+		middleware.Logging(),
+		authService.AuthMiddleware(),
+		middleware.Method("GET"),
+	))
+
 
 	http.HandleFunc("/triangle", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w,r,"./views/triangle.html")
@@ -95,8 +146,15 @@ func main() {
 	//}()
 	//Server Start
 	fmt.Println("Server running at http://localhost:8080/")
-	err := http.ListenAndServe("0.0.0.0:8080", nil)
+	err = http.ListenAndServe("0.0.0.0:8080", nil)
 	if err != nil {
 		panic("Couldnt start server")
 	}
 }
+
+func envOr(key, fallback string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,58 @@
+package outbox
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// Smoothing gains for RTT and jitter, as TCP uses them (RFC 6298).
+const (
+	rttGain    = 1.0 / 8
+	jitterGain = 1.0 / 4
+)
+
+// ping sends the time since epoch, the client echoes it in its pong.
+func (o *Outbox) ping() error {
+	var payload [8]byte
+	binary.LittleEndian.PutUint64(payload[:], uint64(time.Since(o.epoch)))
+	if err := o.t.SetWriteDeadline(time.Now().Add(o.cfg.WriteTimeout)); err != nil {
+		return err
+	}
+	return o.t.Ping(payload[:])
+}
+
+// handlePong runs on the reading goroutine.
+func (o *Outbox) handlePong(data []byte) {
+	o.extendReadDeadline()
+
+	if len(data) != 8 {
+		return
+	}
+	sent := time.Duration(binary.LittleEndian.Uint64(data))
+	sample := time.Since(o.epoch) - sent
+	if sample < 0 {
+		return
+	}
+
+	o.rttMu.Lock()
+	defer o.rttMu.Unlock()
+	if o.srtt == 0 {
+		o.srtt, o.jitter = sample, sample/2
+		return
+	}
+	o.jitter += time.Duration(jitterGain * float64((o.srtt-sample).Abs()-o.jitter))
+	o.srtt += time.Duration(rttGain * float64(sample-o.srtt))
+}
+
+func (o *Outbox) extendReadDeadline() {
+	if o.cfg.PongTimeout > 0 {
+		_ = o.t.SetReadDeadline(time.Now().Add(o.cfg.PongTimeout))
+	}
+}
+
+// RTT returns the smoothed round trip time and its jitter, zero before the first pong.
+func (o *Outbox) RTT() (rtt, jitter time.Duration) {
+	o.rttMu.RLock()
+	defer o.rttMu.RUnlock()
+	return o.srtt, o.jitter
+}
@@ -0,0 +1,212 @@
+package outbox
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"game/protocol"
+	"game/transport"
+)
+
+// Config bounds how far a connection may fall behind and how long it may stay silent.
+type Config struct {
+	QueueSize    int           // messages waiting to be written
+	WriteTimeout time.Duration // per message
+	MaxBacklog   time.Duration // how long the queue may stay over half full
+
+	PingInterval time.Duration // zero sends no pings
+	PongTimeout  time.Duration // reads fail when no pong came for this long, zero never
+}
+
+// Outbox owns the writing side of a transport. Messages are
+// queued without blocking and written by a goroutine of its own, so a slow
+// client never holds up the caller. Clients that stay backed up are disconnected.
+type Outbox struct {
+	t   transport.Transport
+	cfg Config
+	log *log.Logger
+
+	mu           sync.Mutex
+	queue        [][]byte
+	backlogSince time.Time // zero while the queue is at most half full
+
+	wake chan struct{}
+	done chan struct{}
+	once sync.Once
+
+	epoch  time.Time // pings carry the time since epoch
+	rttMu  sync.RWMutex
+	srtt   time.Duration
+	jitter time.Duration
+}
+
+// New starts the writer goroutine, nothing else may send on t afterwards.
+// It also installs the pong handler and the read deadline that goes with it,
+// call it before the first read that follows the handshake.
+func New(t transport.Transport, cfg Config, l *log.Logger) *Outbox {
+	o := &Outbox{
+		t:     t,
+		cfg:   cfg,
+		log:   l,
+		queue: make([][]byte, 0, cfg.QueueSize),
+		wake:  make(chan struct{}, 1),
+		done:  make(chan struct{}),
+		epoch: time.Now(),
+	}
+	o.extendReadDeadline()
+	t.SetPongHandler(o.handlePong)
+
+	go o.run()
+	return o
+}
+
+func (o *Outbox) Transport() transport.Transport {
+	return o.t
+}
+
+// Done is closed once the outbox has closed its connection.
+func (o *Outbox) Done() <-chan struct{} {
+	return o.done
+}
+
+// Send queues msg, which is copied so callers may reuse the buffer.
+// An unwritten message of a supersedable type is replaced by the newer one.
+func (o *Outbox) Send(msg []byte) {
+	var msgType protocol.MsgType
+	if h, err := protocol.ParseHeader(msg); err == nil {
+		msgType = h.Type
+	}
+
+	o.mu.Lock()
+	select {
+	case <-o.done:
+		o.mu.Unlock()
+		return
+	default:
+	}
+
+	if msgType.Supersedable() {
+		o.dropLocked(msgType)
+	}
+
+	backedUp := o.pushLocked(append([]byte(nil), msg...))
+	o.mu.Unlock()
+
+	if backedUp {
+		o.log.Println("Send queue backed up, disconnecting")
+		o.Close()
+		return
+	}
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// dropLocked removes the queued message of type t, there is at most one.
+func (o *Outbox) dropLocked(t protocol.MsgType) {
+	for i, queued := range o.queue {
+		if h, err := protocol.ParseHeader(queued); err == nil && h.Type == t {
+			o.queue = append(o.queue[:i], o.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// pushLocked appends msg and reports whether the client is too far behind to keep.
+func (o *Outbox) pushLocked(msg []byte) bool {
+	if len(o.queue) >= o.cfg.QueueSize {
+		return true
+	}
+	o.queue = append(o.queue, msg)
+
+	if len(o.queue) <= o.cfg.QueueSize/2 {
+		o.backlogSince = time.Time{}
+		return false
+	}
+	if o.backlogSince.IsZero() {
+		o.backlogSince = time.Now()
+		return false
+	}
+	return time.Since(o.backlogSince) > o.cfg.MaxBacklog
+}
+
+func (o *Outbox) run() {
+	var pings <-chan time.Time
+	if o.cfg.PingInterval > 0 {
+		ticker := time.NewTicker(o.cfg.PingInterval)
+		defer ticker.Stop()
+		pings = ticker.C
+	}
+
+	var batch [][]byte
+	for {
+		select {
+		case <-o.done:
+			return
+		case <-pings:
+			if err := o.ping(); err != nil {
+				o.fail(err)
+				return
+			}
+			continue
+		case <-o.wake:
+		}
+
+		// Taking the whole queue empties it, the backlog restarts from here
+		o.mu.Lock()
+		batch, o.queue = o.queue, batch[:0]
+		o.backlogSince = time.Time{}
+		o.mu.Unlock()
+
+		for _, msg := range batch {
+			if err := o.write(msg); err != nil {
+				o.fail(err)
+				return
+			}
+		}
+		clear(batch)
+	}
+}
+
+func (o *Outbox) fail(err error) {
+	// Writes fail once Close has run, that is not worth a log line
+	select {
+	case <-o.done:
+	default:
+		o.log.Println("Write error:", err)
+	}
+	o.Close()
+}
+
+func (o *Outbox) write(msg []byte) error {
+	if err := o.t.SetWriteDeadline(time.Now().Add(o.cfg.WriteTimeout)); err != nil {
+		return err
+	}
+	return o.t.Send(transport.Binary, msg)
+}
+
+// CloseWith tells the client why it is being disconnected, then closes.
+func (o *Outbox) CloseWith(code int, reason string) error {
+	return o.close(func() error { return o.t.CloseWith(code, reason) })
+}
+
+// Close stops the writer and closes the connection, it is safe to call more than once.
+// Messages still queued are dropped.
+func (o *Outbox) Close() error {
+	return o.close(o.t.Close)
+}
+
+func (o *Outbox) close(closeTransport func() error) error {
+	var err error
+	o.once.Do(func() {
+		o.mu.Lock()
+		close(o.done)
+		o.queue = nil
+		o.mu.Unlock()
+		err = closeTransport()
+	})
+	return err
+}
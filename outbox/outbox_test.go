@@ -0,0 +1,148 @@
+package outbox
+
+import (
+	"io"
+	"log"
+	"slices"
+	"testing"
+	"time"
+
+	"game/protocol"
+	"game/transport"
+)
+
+// idleOutbox is an Outbox whose writer is not running, so queued messages stay queued.
+func idleOutbox(t transport.Transport, cfg Config) *Outbox {
+	return &Outbox{
+		t:     t,
+		cfg:   cfg,
+		log:   log.New(io.Discard, "", 0),
+		queue: make([][]byte, 0, cfg.QueueSize),
+		wake:  make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+}
+
+func message(t protocol.MsgType, payload ...byte) []byte {
+	buf, offset := protocol.New(t, 0, len(payload))
+	copy(buf[offset:], payload)
+	return buf
+}
+
+func queuedTypes(o *Outbox) []protocol.MsgType {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var types []protocol.MsgType
+	for _, msg := range o.queue {
+		h, _ := protocol.ParseHeader(msg)
+		types = append(types, h.Type)
+	}
+	return types
+}
+
+func TestSendCoalescesSupersedableMessages(t *testing.T) {
+	tests := []struct {
+		name string
+		sent []protocol.MsgType
+		want []protocol.MsgType
+	}{
+		{"events are kept", []protocol.MsgType{protocol.MsgChat, protocol.MsgChat}, []protocol.MsgType{protocol.MsgChat, protocol.MsgChat}},
+		{"newer state replaces older",
+			[]protocol.MsgType{protocol.MsgScoreboard, protocol.MsgChat, protocol.MsgScoreboard},
+			[]protocol.MsgType{protocol.MsgChat, protocol.MsgScoreboard}},
+		{"one of each type",
+			[]protocol.MsgType{protocol.MsgSnapshot, protocol.MsgMatchPhase, protocol.MsgSnapshot, protocol.MsgMatchPhase},
+			[]protocol.MsgType{protocol.MsgSnapshot, protocol.MsgMatchPhase}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := idleOutbox(nil, Config{QueueSize: 16, MaxBacklog: time.Hour})
+			for _, msgType := range tt.sent {
+				o.Send(message(msgType))
+			}
+			if got := queuedTypes(o); !slices.Equal(got, tt.want) {
+				t.Fatalf("queued %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendCopiesMessage(t *testing.T) {
+	o := idleOutbox(nil, Config{QueueSize: 4, MaxBacklog: time.Hour})
+	msg := message(protocol.MsgChat, 1)
+	o.Send(msg)
+	msg[protocol.HeaderSize] = 2
+
+	if got := o.queue[0][protocol.HeaderSize]; got != 1 {
+		t.Fatalf("queued payload changed to %d with the caller's buffer", got)
+	}
+}
+
+func TestSendDisconnectsBackedUpClient(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		sends   int
+		wait    time.Duration // between the last two sends
+		dropped bool
+	}{
+		{"under half full", Config{QueueSize: 8, MaxBacklog: time.Millisecond}, 4, 10 * time.Millisecond, false},
+		{"backlog within limit", Config{QueueSize: 8, MaxBacklog: time.Hour}, 6, 10 * time.Millisecond, false},
+		{"backlog too long", Config{QueueSize: 8, MaxBacklog: time.Millisecond}, 6, 10 * time.Millisecond, true},
+		{"queue full", Config{QueueSize: 4, MaxBacklog: time.Hour}, 5, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := transport.Pipe()
+			o := idleOutbox(server, tt.cfg)
+
+			for i := range tt.sends {
+				if i == tt.sends-1 {
+					time.Sleep(tt.wait)
+				}
+				o.Send(message(protocol.MsgChat))
+			}
+
+			select {
+			case <-o.Done():
+				if !tt.dropped {
+					t.Fatal("client disconnected")
+				}
+			default:
+				if tt.dropped {
+					t.Fatal("client still connected")
+				}
+			}
+		})
+	}
+}
+
+func TestOutboxWritesInOrderUntilClosed(t *testing.T) {
+	server, client := transport.Pipe()
+	o := New(server, Config{QueueSize: 16, WriteTimeout: time.Second, MaxBacklog: time.Second}, log.New(io.Discard, "", 0))
+
+	for i := range 3 {
+		o.Send(message(protocol.MsgChat, byte(i)))
+	}
+	for i := range 3 {
+		kind, msg, err := client.Receive()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kind != transport.Binary || msg[protocol.HeaderSize] != byte(i) {
+			t.Fatalf("message %d: kind %d payload %v", i, kind, msg[protocol.HeaderSize:])
+		}
+	}
+
+	o.Close()
+	o.Send(message(protocol.MsgChat, 9))
+	if err := client.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if _, msg, err := client.Receive(); err == nil {
+		t.Fatalf("read %v after Close", msg)
+	}
+}
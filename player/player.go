@@ -1,30 +1,62 @@
 package player
 
 import (
-	"github.com/gorilla/websocket"
 	"log"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"game/core"
+	"game/outbox"
+	"game/transport"
 )
 
 type Player struct {
 	core.Concrete
 	userID      string
 	VelocityVec core.Vector
-	conn        *websocket.Conn
+	out         atomic.Pointer[outbox.Outbox]
 	log         *log.Logger
-	writeMu     sync.Mutex
 	pxps        float32
+	basePxps    float32
+	statuses    core.StatusList
+	abilities   core.AbilitySet
+	facing      core.Vector
+	team        uint8
+	health      int
+	maxHealth   int
+	shield      int
+	state       core.StateMachine
+	attacked    bool
+	profileMu   sync.RWMutex
+	profile     Profile
+	ackedInput  atomic.Uint32
 }
 
-func NewPlayer(id int, userID string, x, y, pxps float32, conn *websocket.Conn, l *log.Logger) *Player {
+// Profile is how a player presents itself to others.
+// Color, Skin and Avatar index the client's palettes, Avatar 0 means none.
+type Profile struct {
+	Name   string `json:"name"`
+	Color  uint8  `json:"color"`
+	Skin   uint8  `json:"skin"`
+	Avatar uint8  `json:"avatar"`
+}
+
+const (
+	DefaultMaxHealth = 100
+	MaxShield        = 100
+)
+
+func NewPlayer(id int, userID string, x, y, pxps float32, l *log.Logger) *Player {
 	p := &Player{
 		userID:      userID,
 		VelocityVec: core.Vector{VX: 0, VY: 0},
-		conn:        conn,
 		log:         l,
 		pxps:        pxps,
+		basePxps:    pxps,
+		facing:      core.DirRight,
+		health:      DefaultMaxHealth,
+		maxHealth:   DefaultMaxHealth,
 		Concrete:    *core.NewConcreteObject(id,nil,core.Point{X:x, Y:y}),
 
 	}
@@ -37,13 +69,75 @@ func (p *Player) UserID() string {
 }
 
 
-func (p *Player) State() string {
-	return "Happy"
+func (p *Player) State() core.CharacterState {
+	return p.state.Current()
+}
+
+// StateChanged reports whether the state changed since the last call.
+func (p *Player) StateChanged() bool {
+	return p.state.TakeChanged()
+}
+
+// updateState derives the state from health, statuses, casts and velocity.
+// Respawning is held until the player first moves or acts.
+func (p *Player) updateState() {
+	current := p.state.Current()
+	next := current
+
+	switch {
+	case !p.IsAlive():
+		next = core.StateDead
+	case p.statuses.BlocksMovement():
+		next = core.StateStunned
+	case p.attacked || p.abilities.Casting():
+		next = core.StateAttacking
+	case p.VelocityVec.Length() > 0:
+		next = core.StateMoving
+	case current != core.StateRespawning:
+		next = core.StateIdle
+	}
+	p.attacked = false
+
+	if err := p.state.Transition(next); err != nil {
+		p.log.Println("State", current, "->", next, ":", err)
+	}
+}
+
+func (p *Player) Profile() Profile {
+	p.profileMu.RLock()
+	defer p.profileMu.RUnlock()
+	return p.profile
+}
+
+func (p *Player) SetProfile(profile Profile) {
+	p.profileMu.Lock()
+	defer p.profileMu.Unlock()
+	p.profile = profile
+}
+
+func (p *Player) Team() uint8 {
+	return p.team
+}
+
+func (p *Player) SetTeam(team uint8) {
+	p.team = team
 }
 
 func (p *Player) OnTick(delta float64) {
+	p.statuses.Tick(p)
+	p.abilities.Tick(p)
+	p.refreshSpeed()
+	p.updateState()
+
 	p.Position.X += p.VelocityVec.VX * float32(delta) 
 	p.Position.Y += p.VelocityVec.VY * float32(delta)
+
+	// Carried objects move with their carrier
+	for _, child := range p.Children() {
+		if conc, ok := child.(core.ConcreteObject); ok {
+			conc.SetPosition(p.Position)
+		}
+	}
 }
 
 func (p *Player) OnFrame(delta float64) {
@@ -69,27 +163,239 @@ func (p *Player) ApplyAcceleration(v *core.Vector) {
 	p.VelocityVec.VY += v.VY
 }
 
-func (p *Player) Conn() *websocket.Conn {
-	return p.conn
+// Transport returns the player's current connection, nil before the first Attach.
+func (p *Player) Transport() transport.Transport {
+	if out := p.out.Load(); out != nil {
+		return out.Transport()
+	}
+	return nil
 }
 
 func (p *Player) CloseConn() error {
-	return p.conn.Close()
+	if out := p.out.Load(); out != nil {
+		return out.Close()
+	}
+	return nil
 }
 
-func(p *Player) SetConn(c *websocket.Conn) {
-	p.conn = c
+// Attach makes t the player's connection, closing the one it replaces.
+// Writes go through an outbox bounded by cfg, so Notify never waits on the network.
+func (p *Player) Attach(t transport.Transport, cfg outbox.Config) *outbox.Outbox {
+	out := outbox.New(t, cfg, p.log)
+	if old := p.out.Swap(out); old != nil {
+		old.Close()
+	}
+	return out
+}
+
+// Latency returns the smoothed round trip time to the client and its jitter.
+func (p *Player) Latency() (rtt, jitter time.Duration) {
+	if out := p.out.Load(); out != nil {
+		return out.RTT()
+	}
+	return 0, 0
 }
 
+// Notify queues bytes for the player, the bytes are copied.
 func (p *Player) Notify(bytes []byte) {
-	if p.conn != nil {
-		p.writeMu.Lock()
-		defer p.writeMu.Unlock()
-		if err := p.conn.WriteMessage(websocket.BinaryMessage, bytes); err != nil {
-			p.log.Println("Write error to player", p.ID(), ":", err)
-		}
+	if out := p.out.Load(); out != nil {
+		out.Send(bytes)
+	}
+}
+
+
+func (p *Player) Health() int {
+	return p.health
+}
+
+func (p *Player) MaxHealth() int {
+	return p.maxHealth
+}
+
+func (p *Player) IsAlive() bool {
+	return p.health > 0
+}
+
+// TakeDamage reports whether the hit was lethal.
+// The shield absorbs damage before health does.
+func (p *Player) TakeDamage(amount int) bool {
+	if !p.IsAlive() || amount <= 0 || p.statuses.BlocksDamage() {
+		return false
+	}
+
+	absorbed := min(p.shield, amount)
+	p.shield -= absorbed
+	amount -= absorbed
+
+	p.health -= amount
+	if p.health > 0 {
+		return false
+	}
+
+	p.health = 0
+	p.SetVelocity(&core.DirStop)
+	_ = p.state.Transition(core.StateDead)
+	return true
+}
+
+// SetMaxHealth changes the health cap, current health is clamped to it.
+func (p *Player) SetMaxHealth(maxHealth int) {
+	if maxHealth <= 0 {
+		return
+	}
+	p.maxHealth = maxHealth
+	p.health = min(p.health, maxHealth)
+}
+
+func (p *Player) SetBaseSpeed(pxps float32) {
+	if pxps <= 0 {
+		return
+	}
+	p.basePxps = pxps
+	p.refreshSpeed()
+}
+
+func (p *Player) Heal(amount int) {
+	if !p.IsAlive() || amount <= 0 {
+		return
+	}
+	p.health = min(p.health+amount, p.maxHealth)
+}
+
+func (p *Player) Revive() {
+	p.health = p.maxHealth
+	p.shield = 0
+	p.statuses.Clear()
+	p.abilities.Reset()
+	p.refreshSpeed()
+	_ = p.state.Transition(core.StateRespawning)
+}
+
+func (p *Player) Shield() int {
+	return p.shield
+}
+
+func (p *Player) AddShield(amount int) {
+	if amount <= 0 {
+		return
+	}
+	p.shield = min(p.shield+amount, MaxShield)
+}
+
+func (p *Player) AddStatus(s core.StatusEffect) {
+	p.statuses.Add(p, s)
+	if p.statuses.BlocksMovement() {
+		p.abilities.Interrupt()
+	}
+	p.refreshSpeed()
+}
+
+func (p *Player) Dispel(tag string) int {
+	removed := p.statuses.Dispel(p, tag)
+	p.refreshSpeed()
+	return removed
+}
+
+func (p *Player) ActiveStatuses() []core.ActiveStatus {
+	return p.statuses.Snapshot()
+}
+
+// StatusesChanged reports whether statuses were added or removed since the last call.
+func (p *Player) StatusesChanged() bool {
+	return p.statuses.TakeDirty()
+}
+
+func (p *Player) BindAbility(slot int, a core.Ability) {
+	p.abilities.Bind(slot, a)
+}
+
+func (p *Player) UseAbility(slot int) error {
+	if !p.IsAlive() || p.statuses.BlocksMovement() || !p.State().AcceptsAbility() {
+		return core.ErrAbilityBlocked
+	}
+	err := p.abilities.Use(p, slot)
+	if err == nil {
+		p.attacked = true
 	}
+	p.refreshSpeed()
+	return err
+}
 
+func (p *Player) AbilityStates() []core.AbilityState {
+	return p.abilities.Snapshot()
+}
+
+// AbilitiesChanged reports whether charges or casts changed since the last call.
+func (p *Player) AbilitiesChanged() bool {
+	return p.abilities.TakeDirty()
+}
+
+// AckInput records that the client's input with sequence seq has been applied.
+func (p *Player) AckInput(seq uint32) {
+	p.ackedInput.Store(seq)
+}
+
+// AckedInput is the sequence of the last input applied to the player.
+func (p *Player) AckedInput() uint32 {
+	return p.ackedInput.Load()
+}
+
+// Facing is the unit direction of the last movement or aim input.
+func (p *Player) Facing() core.Vector {
+	return p.facing
+}
+
+// SetFacing turns the player without moving it, zero vectors are ignored.
+func (p *Player) SetFacing(direction core.Vector) {
+	if length := direction.Length(); length > 0 {
+		p.facing = core.Vector{VX: direction.VX / length, VY: direction.VY / length}
+	}
+}
+
+// refreshSpeed recomputes pxps from the active statuses and
+// rescales the current velocity to match.
+func (p *Player) refreshSpeed() {
+	pxps := p.basePxps * p.statuses.SpeedFactor()
+	if pxps == p.pxps {
+		return
+	}
+	if p.pxps != 0 {
+		p.VelocityVec.Scale(pxps / p.pxps)
+	}
+	p.pxps = pxps
+}
+
+//Serializable
+// Player layout: [Concrete][1 byte team][1 byte state]
+
+func (p *Player) ToBytes(buf []byte, start int) int {
+	offset := start
+	offset += p.Concrete.ToBytes(buf, offset)
+
+	buf[offset] = p.team
+	buf[offset+1] = byte(p.State())
+	offset += 2
+
+	return offset - start
+}
+
+func (p *Player) ToDeltaBytes(buf []byte, start int) int {
+	n := p.Concrete.ToDeltaBytes(buf, start)
+	if n == 0 {
+		return 0
+	}
+
+	buf[start+n] = p.team
+	buf[start+n+1] = byte(p.State())
+	return n + 2
+}
+
+func (p *Player) Size() int {
+	return p.Concrete.Size() + 2
+}
+
+func (p *Player) DeltaSize() int {
+	return p.Concrete.DeltaSize() + 2
 }
 
 
@@ -125,7 +431,22 @@ func (p *Player) Move(rawInput string) {
 		return
 	}
 
-	p.SetVelocity(&baseDirection) 
-	p.Velocity().Scale(p.pxps)
+	p.MoveAnalog(baseDirection)
 }
 
+
+// MoveAnalog moves along direction scaled by pxps. The direction is clamped
+// to unit length so analog input can never exceed the player's speed.
+func (p *Player) MoveAnalog(direction core.Vector) {
+	if p.statuses.BlocksMovement() || !p.State().AcceptsMovement() {
+		p.SetVelocity(&core.DirStop)
+		return
+	}
+
+	direction.ClampLength(1)
+	if length := direction.Length(); length > 0 {
+		p.facing = core.Vector{VX: direction.VX / length, VY: direction.VY / length}
+	}
+	p.SetVelocity(&direction)
+	p.Velocity().Scale(p.pxps)
+}
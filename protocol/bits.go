@@ -0,0 +1,151 @@
+package protocol
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+)
+
+var (
+	ErrBitsExhausted  = errors.New("read past the end of the bit stream")
+	ErrVarintOverflow = errors.New("varint longer than 64 bits")
+)
+
+// BitWriter packs values into bytes least significant bit first.
+type BitWriter struct {
+	buf []byte
+	n   uint // bits written
+}
+
+// NewBitWriter writes into the storage of buf, which may be nil.
+func NewBitWriter(buf []byte) *BitWriter {
+	return &BitWriter{buf: buf[:0]}
+}
+
+// WriteBits writes the low n bits of v, n is at most 64.
+func (w *BitWriter) WriteBits(v uint64, n uint) {
+	for n > 0 {
+		used := w.n % 8
+		if used == 0 {
+			w.buf = append(w.buf, 0)
+		}
+		take := min(8-used, n)
+		w.buf[len(w.buf)-1] |= byte(v&(1<<take-1)) << used
+		v >>= take
+		n -= take
+		w.n += take
+	}
+}
+
+func (w *BitWriter) WriteBool(b bool) {
+	if b {
+		w.WriteBits(1, 1)
+	} else {
+		w.WriteBits(0, 1)
+	}
+}
+
+// WriteVarint writes v in groups of 7 bits, each followed by a bit
+// telling whether another group follows. Values below 128 take 8 bits.
+func (w *BitWriter) WriteVarint(v uint64) {
+	for v >= 0x80 {
+		w.WriteBits(v&0x7f, 7)
+		w.WriteBits(1, 1)
+		v >>= 7
+	}
+	w.WriteBits(v, 7)
+	w.WriteBits(0, 1)
+}
+
+// Bytes returns the packed stream, the last byte is padded with zeros.
+func (w *BitWriter) Bytes() []byte {
+	return w.buf
+}
+
+// Len returns the number of bytes Bytes would return.
+func (w *BitWriter) Len() int {
+	return len(w.buf)
+}
+
+func (w *BitWriter) Reset() {
+	w.buf = w.buf[:0]
+	w.n = 0
+}
+
+// BitReader reads a stream written by BitWriter.
+type BitReader struct {
+	buf []byte
+	n   uint // bits read
+}
+
+func NewBitReader(buf []byte) *BitReader {
+	return &BitReader{buf: buf}
+}
+
+// ReadBits reads n bits, n is at most 64.
+func (r *BitReader) ReadBits(n uint) (uint64, error) {
+	if r.n+n > uint(len(r.buf))*8 {
+		return 0, ErrBitsExhausted
+	}
+
+	var v uint64
+	var shift uint
+	for n > 0 {
+		used := r.n % 8
+		take := min(8-used, n)
+		chunk := uint64(r.buf[r.n/8]>>used) & (1<<take - 1)
+		v |= chunk << shift
+		shift += take
+		n -= take
+		r.n += take
+	}
+	return v, nil
+}
+
+func (r *BitReader) ReadBool() (bool, error) {
+	v, err := r.ReadBits(1)
+	return v == 1, err
+}
+
+func (r *BitReader) ReadVarint() (uint64, error) {
+	var v uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		group, err := r.ReadBits(7)
+		if err != nil {
+			return 0, err
+		}
+		v |= group << shift
+
+		more, err := r.ReadBool()
+		if err != nil || !more {
+			return v, err
+		}
+	}
+	return 0, ErrVarintOverflow
+}
+
+// Quantizer maps a float in [Min, Max] onto an unsigned integer of Bits bits.
+// Values outside the range are clamped.
+type Quantizer struct {
+	Min  float32
+	Max  float32
+	Bits uint
+}
+
+// NewQuantizer picks the fewest bits that keep values in [lo, hi]
+// within step of their original value.
+func NewQuantizer(lo, hi, step float32) Quantizer {
+	steps := uint64(math.Ceil(float64((hi - lo) / step)))
+	return Quantizer{Min: lo, Max: hi, Bits: uint(max(1, bits.Len64(steps)))}
+}
+
+func (q Quantizer) Quantize(v float32) uint64 {
+	top := float64(uint64(1)<<q.Bits - 1)
+	t := (float64(v) - float64(q.Min)) / float64(q.Max-q.Min)
+	return uint64(math.Round(math.Min(math.Max(t, 0), 1) * top))
+}
+
+func (q Quantizer) Dequantize(v uint64) float32 {
+	top := float64(uint64(1)<<q.Bits - 1)
+	return q.Min + float32(float64(v)/top)*(q.Max-q.Min)
+}
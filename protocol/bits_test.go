@@ -0,0 +1,83 @@
+package protocol
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestBitRoundTrip(t *testing.T) {
+	type field struct {
+		v uint64
+		n uint
+	}
+	fields := []field{{1, 1}, {5, 3}, {0, 4}, {0x3ff, 10}, {0, 1}, {math.MaxUint64, 64}, {0x1234, 13}, {7, 3}}
+	varints := []uint64{0, 1, 127, 128, 300, 1 << 35, math.MaxUint64}
+
+	w := NewBitWriter(nil)
+	for _, f := range fields {
+		w.WriteBits(f.v, f.n)
+	}
+	w.WriteBool(true)
+	for _, v := range varints {
+		w.WriteVarint(v)
+	}
+
+	r := NewBitReader(w.Bytes())
+	for _, f := range fields {
+		got, err := r.ReadBits(f.n)
+		if err != nil || got != f.v {
+			t.Fatalf("ReadBits(%d) = %d, %v, want %d", f.n, got, err, f.v)
+		}
+	}
+	if b, err := r.ReadBool(); err != nil || !b {
+		t.Fatalf("ReadBool = %v, %v, want true", b, err)
+	}
+	for _, v := range varints {
+		if got, err := r.ReadVarint(); err != nil || got != v {
+			t.Fatalf("ReadVarint = %d, %v, want %d", got, err, v)
+		}
+	}
+}
+
+func TestBitWriterReset(t *testing.T) {
+	w := NewBitWriter(make([]byte, 0, 4))
+	w.WriteBits(0xff, 8)
+	w.Reset()
+	w.WriteBits(1, 1)
+	if w.Len() != 1 || w.Bytes()[0] != 1 {
+		t.Fatalf("after Reset got %x, want 01", w.Bytes())
+	}
+}
+
+func TestBitReaderExhausted(t *testing.T) {
+	r := NewBitReader([]byte{0xff})
+	if _, err := r.ReadBits(9); !errors.Is(err, ErrBitsExhausted) {
+		t.Fatalf("ReadBits past the end: %v, want ErrBitsExhausted", err)
+	}
+
+	// Every group says another follows
+	r = NewBitReader([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	if _, err := r.ReadVarint(); !errors.Is(err, ErrVarintOverflow) {
+		t.Fatalf("ReadVarint of 10 continued groups: %v, want ErrVarintOverflow", err)
+	}
+}
+
+func TestQuantizer(t *testing.T) {
+	const step = 1.0 / 16
+	q := NewQuantizer(0, 1200, step)
+
+	if q.Bits != 15 {
+		t.Fatalf("Bits = %d, want 15", q.Bits)
+	}
+	for _, v := range []float32{0, 0.03, 1, 123.456, 599.99, 1199.97, 1200} {
+		got := q.Dequantize(q.Quantize(v))
+		if math.Abs(float64(got-v)) > step {
+			t.Errorf("round trip of %v = %v, off by more than %v", v, got, step)
+		}
+	}
+
+	if q.Quantize(-50) != 0 || q.Quantize(5000) != 1<<q.Bits-1 {
+		t.Errorf("values outside [Min, Max] are not clamped")
+	}
+}
@@ -0,0 +1,133 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// ClientMsgType identifies a binary client message by its first byte.
+type ClientMsgType uint8
+
+const (
+	ClientInput ClientMsgType = iota + 1
+	ClientSnapshotAck
+	ClientTimeSync
+)
+
+// InputSize is the exact length of a ClientInput message.
+// Layout: [1 byte message id][4 bytes sequence][2 bytes buttons]
+// [4 bytes move x f32][4 bytes move y f32][4 bytes aim f32 radians]
+const InputSize = 19
+
+// SnapshotAckSize is the exact length of a ClientSnapshotAck message.
+// Layout: [1 byte message id][4 bytes tick of the snapshot received]
+const SnapshotAckSize = 5
+
+// TimeSyncSize is the exact length of a ClientTimeSync message.
+// Layout: [1 byte message id][8 bytes client send time f64 ms]
+const TimeSyncSize = 9
+
+// Buttons, every other bit is reserved and must be zero.
+const (
+	ButtonPrimary uint16 = 1 << iota
+	ButtonSecondary
+	ButtonAim // the aim field is valid
+
+	buttonsMask = ButtonPrimary | ButtonSecondary | ButtonAim
+)
+
+// maxMove leaves room for float rounding on clients that normalise their vector.
+const maxMove = 1.001
+
+var (
+	ErrUnknownClientMsg = errors.New("unknown client message")
+	ErrInputLength      = errors.New("input message has the wrong length")
+	ErrAckLength        = errors.New("ack message has the wrong length")
+	ErrTimeSyncLength   = errors.New("time sync message has the wrong length")
+	ErrInputButtons     = errors.New("input uses reserved buttons")
+	ErrInputMove        = errors.New("input movement out of range")
+	ErrInputAim         = errors.New("input aim out of range")
+)
+
+// Input is one sample of a client's controls.
+type Input struct {
+	Sequence uint32
+	Buttons  uint16
+	MoveX    float32
+	MoveY    float32
+	Aim      float32
+}
+
+func (in Input) Pressed(button uint16) bool {
+	return in.Buttons&button != 0
+}
+
+// ClientMsgTypeOf returns the type of a binary client message.
+func ClientMsgTypeOf(buf []byte) (ClientMsgType, error) {
+	if len(buf) == 0 {
+		return 0, ErrUnknownClientMsg
+	}
+	return ClientMsgType(buf[0]), nil
+}
+
+// DecodeInput parses and validates a ClientInput message.
+func DecodeInput(buf []byte) (Input, error) {
+	if len(buf) != InputSize {
+		return Input{}, ErrInputLength
+	}
+	if ClientMsgType(buf[0]) != ClientInput {
+		return Input{}, ErrUnknownClientMsg
+	}
+
+	in := Input{
+		Sequence: binary.LittleEndian.Uint32(buf[1:5]),
+		Buttons:  binary.LittleEndian.Uint16(buf[5:7]),
+		MoveX:    math.Float32frombits(binary.LittleEndian.Uint32(buf[7:11])),
+		MoveY:    math.Float32frombits(binary.LittleEndian.Uint32(buf[11:15])),
+		Aim:      math.Float32frombits(binary.LittleEndian.Uint32(buf[15:19])),
+	}
+
+	if in.Buttons&^buttonsMask != 0 {
+		return Input{}, ErrInputButtons
+	}
+
+	x, y := float64(in.MoveX), float64(in.MoveY)
+	if !finite(x) || !finite(y) || math.Hypot(x, y) > maxMove {
+		return Input{}, ErrInputMove
+	}
+
+	aim := float64(in.Aim)
+	if !finite(aim) || math.Abs(aim) > math.Pi+1e-6 {
+		return Input{}, ErrInputAim
+	}
+
+	return in, nil
+}
+
+// DecodeSnapshotAck returns the tick acknowledged by a ClientSnapshotAck message.
+func DecodeSnapshotAck(buf []byte) (uint32, error) {
+	if len(buf) != SnapshotAckSize {
+		return 0, ErrAckLength
+	}
+	if ClientMsgType(buf[0]) != ClientSnapshotAck {
+		return 0, ErrUnknownClientMsg
+	}
+	return binary.LittleEndian.Uint32(buf[1:5]), nil
+}
+
+// DecodeTimeSync returns the client clock reading carried by a ClientTimeSync message.
+// It is opaque to the server and only echoed back.
+func DecodeTimeSync(buf []byte) (float64, error) {
+	if len(buf) != TimeSyncSize {
+		return 0, ErrTimeSyncLength
+	}
+	if ClientMsgType(buf[0]) != ClientTimeSync {
+		return 0, ErrUnknownClientMsg
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(buf[1:9])), nil
+}
+
+func finite(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
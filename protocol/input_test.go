@@ -0,0 +1,62 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+)
+
+func encodeInput(in Input) []byte {
+	buf := make([]byte, InputSize)
+	buf[0] = byte(ClientInput)
+	binary.LittleEndian.PutUint32(buf[1:5], in.Sequence)
+	binary.LittleEndian.PutUint16(buf[5:7], in.Buttons)
+	binary.LittleEndian.PutUint32(buf[7:11], math.Float32bits(in.MoveX))
+	binary.LittleEndian.PutUint32(buf[11:15], math.Float32bits(in.MoveY))
+	binary.LittleEndian.PutUint32(buf[15:19], math.Float32bits(in.Aim))
+	return buf
+}
+
+func TestDecodeInput(t *testing.T) {
+	nan := float32(math.NaN())
+	inf := float32(math.Inf(1))
+	valid := Input{Sequence: 7, Buttons: ButtonPrimary | ButtonAim, MoveX: 0.6, MoveY: -0.8, Aim: math.Pi / 2}
+
+	tests := []struct {
+		name string
+		buf  []byte
+		want error
+	}{
+		{"valid", encodeInput(valid), nil},
+		{"full diagonal", encodeInput(Input{MoveX: math.Sqrt2 / 2, MoveY: math.Sqrt2 / 2}), nil},
+		{"aim at pi", encodeInput(Input{Buttons: ButtonAim, Aim: -math.Pi}), nil},
+		{"empty", nil, ErrInputLength},
+		{"short", encodeInput(valid)[:InputSize-1], ErrInputLength},
+		{"long", append(encodeInput(valid), 0), ErrInputLength},
+		{"unknown message", func() []byte { buf := encodeInput(valid); buf[0] = 9; return buf }(), ErrUnknownClientMsg},
+		{"reserved button", encodeInput(Input{Buttons: 1 << 3}), ErrInputButtons},
+		{"move too long", encodeInput(Input{MoveX: 1, MoveY: 0.1}), ErrInputMove},
+		{"move not a number", encodeInput(Input{MoveX: nan}), ErrInputMove},
+		{"move infinite", encodeInput(Input{MoveY: -inf}), ErrInputMove},
+		{"aim out of range", encodeInput(Input{Aim: 4}), ErrInputAim},
+		{"aim not a number", encodeInput(Input{Aim: nan}), ErrInputAim},
+		{"aim infinite", encodeInput(Input{Aim: inf}), ErrInputAim},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in, err := DecodeInput(tt.buf)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err %v, want %v", err, tt.want)
+			}
+			if err == nil && !bytes.Equal(encodeInput(in), tt.buf) {
+				t.Fatalf("decoded %+v does not round trip", in)
+			}
+			if err != nil && in != (Input{}) {
+				t.Fatalf("rejected input returned %+v", in)
+			}
+		})
+	}
+}
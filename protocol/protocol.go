@@ -0,0 +1,173 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Version is bumped on every incompatible change to the wire format.
+const Version uint8 = 5
+
+// HeaderSize is the size of the header in front of every server message.
+// Header layout: [1 byte version][2 bytes type][4 bytes tick][4 bytes payload length]
+const HeaderSize = 11
+
+// CloseVersionMismatch is the websocket close code sent to clients
+// that speak another protocol version.
+const CloseVersionMismatch = 4001
+
+// CloseIdle is the websocket close code sent to players disconnected for being idle.
+const CloseIdle = 4002
+
+// CloseSessionExpired is sent to clients resuming a player that was already removed.
+const CloseSessionExpired = 4003
+
+var (
+	ErrShortHeader   = errors.New("message shorter than header")
+	ErrVersion       = errors.New("unsupported protocol version")
+	ErrPayloadLength = errors.New("payload length does not match message")
+)
+
+// MsgType identifies a server message. IDs are part of the protocol,
+// never reuse or renumber them, only append.
+type MsgType uint16
+
+const (
+	MsgWelcome MsgType = iota + 1
+	MsgPositionUpdate
+	MsgPlayerJoined
+	MsgPlayerLeft
+	MsgEnterView
+	MsgLeaveView
+	MsgScoreboard
+	MsgMatchPhase
+	MsgPlayerDied
+	MsgTeamChanged
+	MsgTeamScores
+	MsgFlagEvent
+	MsgChat
+	MsgPickupTaken
+	MsgPickupRespawned
+	MsgStatusEffects
+	MsgAbilityState
+	MsgArena
+	MsgPlayerState
+	MsgPlayerProfile
+	MsgProfileError
+	MsgSpectateTarget
+	MsgInputAck
+	MsgSnapshot
+	MsgCompactSnapshot
+	MsgFullState
+	MsgPing
+	MsgSession
+	MsgTimeSync
+)
+
+var names = map[MsgType]string{
+	MsgWelcome:         "welcome",
+	MsgPositionUpdate:  "position_update",
+	MsgPlayerJoined:    "player_joined",
+	MsgPlayerLeft:      "player_left",
+	MsgEnterView:       "enter_view",
+	MsgLeaveView:       "leave_view",
+	MsgScoreboard:      "scoreboard",
+	MsgMatchPhase:      "match_phase",
+	MsgPlayerDied:      "player_died",
+	MsgTeamChanged:     "team_changed",
+	MsgTeamScores:      "team_scores",
+	MsgFlagEvent:       "flag_event",
+	MsgChat:            "chat_message",
+	MsgPickupTaken:     "pickup_taken",
+	MsgPickupRespawned: "pickup_respawned",
+	MsgStatusEffects:   "status_effects",
+	MsgAbilityState:    "ability_state",
+	MsgArena:           "arena",
+	MsgPlayerState:     "player_state",
+	MsgPlayerProfile:   "player_profile",
+	MsgProfileError:    "profile_error",
+	MsgSpectateTarget:  "spectate_target",
+	MsgInputAck:        "input_ack",
+	MsgSnapshot:        "snapshot",
+	MsgCompactSnapshot: "compact_snapshot",
+	MsgFullState:       "full_state",
+	MsgPing:            "ping",
+	MsgSession:         "session",
+	MsgTimeSync:        "time_sync",
+}
+
+func (t MsgType) String() string {
+	if name, ok := names[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("msg_%d", uint16(t))
+}
+
+// supersedable messages carry a whole piece of state, so a newer one
+// makes an older one that was not written yet redundant.
+var supersedable = map[MsgType]bool{
+	MsgScoreboard:      true,
+	MsgMatchPhase:      true,
+	MsgTeamScores:      true,
+	MsgAbilityState:    true,
+	MsgInputAck:        true,
+	MsgSnapshot:        true,
+	MsgCompactSnapshot: true,
+	MsgFullState:       true,
+}
+
+// Supersedable reports whether a message of type t may be dropped
+// in favour of a newer message of the same type.
+func (t MsgType) Supersedable() bool {
+	return supersedable[t]
+}
+
+type Header struct {
+	Version       uint8
+	Type          MsgType
+	Tick          uint32
+	PayloadLength uint32
+}
+
+// PutHeader writes the header for a payload of payloadSize bytes at the start of buf.
+func PutHeader(buf []byte, t MsgType, tick uint64, payloadSize int) {
+	buf[0] = Version
+	binary.LittleEndian.PutUint16(buf[1:3], uint16(t))
+	binary.LittleEndian.PutUint32(buf[3:7], uint32(tick))
+	binary.LittleEndian.PutUint32(buf[7:11], uint32(payloadSize))
+}
+
+// SetTick rewrites the tick of a message built earlier.
+func SetTick(buf []byte, tick uint64) {
+	binary.LittleEndian.PutUint32(buf[3:7], uint32(tick))
+}
+
+// New allocates a message of type t with room for payloadSize bytes.
+// It returns the buffer and the offset at which the payload starts.
+func New(t MsgType, tick uint64, payloadSize int) ([]byte, int) {
+	buf := make([]byte, HeaderSize+payloadSize)
+	PutHeader(buf, t, tick, payloadSize)
+	return buf, HeaderSize
+}
+
+// ParseHeader reads and checks the header of buf.
+func ParseHeader(buf []byte) (Header, error) {
+	if len(buf) < HeaderSize {
+		return Header{}, ErrShortHeader
+	}
+
+	h := Header{
+		Version:       buf[0],
+		Type:          MsgType(binary.LittleEndian.Uint16(buf[1:3])),
+		Tick:          binary.LittleEndian.Uint32(buf[3:7]),
+		PayloadLength: binary.LittleEndian.Uint32(buf[7:11]),
+	}
+	if h.Version != Version {
+		return h, ErrVersion
+	}
+	if int(h.PayloadLength) != len(buf)-HeaderSize {
+		return h, ErrPayloadLength
+	}
+	return h, nil
+}
@@ -0,0 +1,131 @@
+package spectator
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"game/outbox"
+	"game/transport"
+)
+
+// queueSize bounds the messages held back by the delay,
+// a spectator that overflows it is disconnected.
+const queueSize = 4096
+
+// FreeCam is the follow target of a spectator not following anyone.
+const FreeCam = -1
+
+type delayed struct {
+	at   time.Time
+	data []byte
+}
+
+// Spectator watches a match without occupying a player slot.
+// With a delay every message is held back before it is written,
+// so spectators cannot relay live positions to players.
+type Spectator struct {
+	id     int
+	userID string
+	out    *outbox.Outbox
+	log    *log.Logger
+
+	delay time.Duration
+	queue chan delayed
+	done  chan struct{}
+	once  sync.Once
+
+	followMu sync.RWMutex
+	follow   int
+}
+
+func NewSpectator(id int, userID string, t transport.Transport, delay time.Duration, cfg outbox.Config, l *log.Logger) *Spectator {
+	s := &Spectator{
+		id:     id,
+		userID: userID,
+		out:    outbox.New(t, cfg, l),
+		log:    l,
+		delay:  delay,
+		done:   make(chan struct{}),
+		follow: FreeCam,
+	}
+
+	if delay > 0 {
+		s.queue = make(chan delayed, queueSize)
+		go s.runDelayed()
+	}
+
+	return s
+}
+
+func (s *Spectator) ID() int {
+	return s.id
+}
+
+func (s *Spectator) UserID() string {
+	return s.userID
+}
+
+func (s *Spectator) Transport() transport.Transport {
+	return s.out.Transport()
+}
+
+// Following returns the followed player's ID, or FreeCam.
+func (s *Spectator) Following() int {
+	s.followMu.RLock()
+	defer s.followMu.RUnlock()
+	return s.follow
+}
+
+func (s *Spectator) Follow(playerID int) {
+	s.followMu.Lock()
+	defer s.followMu.Unlock()
+	s.follow = playerID
+}
+
+// Notify sends bytes to the spectator, after the delay if there is one.
+// The bytes are copied, callers may reuse the buffer.
+func (s *Spectator) Notify(bytes []byte) {
+	if s.delay <= 0 {
+		s.out.Send(bytes)
+		return
+	}
+
+	msg := delayed{at: time.Now().Add(s.delay), data: append([]byte(nil), bytes...)}
+
+	select {
+	case <-s.done:
+	case s.queue <- msg:
+	default:
+		s.log.Println("Spectator", s.id, "fell behind, disconnecting")
+		s.Close()
+	}
+}
+
+func (s *Spectator) runDelayed() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case msg := <-s.queue:
+			if wait := time.Until(msg.at); wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-s.done:
+					return
+				}
+			}
+			s.out.Send(msg.data)
+		}
+	}
+}
+
+// Close stops the delay queue and closes the connection, it is safe to call more than once.
+func (s *Spectator) Close() error {
+	var err error
+	s.once.Do(func() {
+		close(s.done)
+		err = s.out.Close()
+	})
+	return err
+}
@@ -0,0 +1,181 @@
+package transport
+
+import (
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// pipeBuffer is how many frames a pipe end holds before Send blocks.
+const pipeBuffer = 64
+
+type frame struct {
+	op   byte
+	data []byte
+}
+
+// pipe is shared by both ends, closing either end closes it.
+type pipe struct {
+	done chan struct{}
+	once sync.Once
+}
+
+// PipeEnd is one end of an in-memory transport. It behaves like a
+// connection to a well-behaved peer, for tests and bots.
+type PipeEnd struct {
+	p    *pipe
+	in   chan frame
+	out  chan frame
+	addr pipeAddr
+	counters
+
+	mu            sync.Mutex
+	readDeadline  time.Time
+	writeDeadline time.Time
+	onPong        func([]byte)
+}
+
+// Pipe returns the two ends of an in-memory transport. Pings are answered
+// by the receiving end while it is in Receive, as a websocket client would.
+func Pipe() (*PipeEnd, *PipeEnd) {
+	p := &pipe{done: make(chan struct{})}
+	a, b := make(chan frame, pipeBuffer), make(chan frame, pipeBuffer)
+	return &PipeEnd{p: p, in: a, out: b, addr: "pipe:b"},
+		&PipeEnd{p: p, in: b, out: a, addr: "pipe:a"}
+}
+
+func (e *PipeEnd) Send(kind Kind, msg []byte) error {
+	if err := e.deliver(frame{byte(kind), append([]byte(nil), msg...)}, e.deadline(&e.writeDeadline)); err != nil {
+		return err
+	}
+	e.sent(len(msg))
+	return nil
+}
+
+// deliver hands f to the peer, waiting for room until deadline.
+func (e *PipeEnd) deliver(f frame, deadline time.Time) error {
+	expired, stop := after(deadline)
+	defer stop()
+
+	select {
+	case <-e.p.done:
+		return ErrClosed
+	default:
+	}
+
+	select {
+	case e.out <- f:
+		return nil
+	case <-e.p.done:
+		return ErrClosed
+	case <-expired:
+		return os.ErrDeadlineExceeded
+	}
+}
+
+func (e *PipeEnd) Receive() (Kind, []byte, error) {
+	for {
+		f, err := e.next()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch f.op {
+		case opPing:
+			if err := e.deliver(frame{opPong, f.data}, e.deadline(&e.writeDeadline)); err != nil {
+				return 0, nil, err
+			}
+		case opPong:
+			e.mu.Lock()
+			h := e.onPong
+			e.mu.Unlock()
+			if h != nil {
+				h(f.data)
+			}
+		case opClose:
+			return 0, nil, parseClose(f.data)
+		default:
+			e.received(len(f.data))
+			return Kind(f.op), f.data, nil
+		}
+	}
+}
+
+// next returns the next frame. Frames sent before the pipe closed are still read.
+func (e *PipeEnd) next() (frame, error) {
+	expired, stop := after(e.deadline(&e.readDeadline))
+	defer stop()
+
+	select {
+	case f := <-e.in:
+		return f, nil
+	case <-expired:
+		return frame{}, os.ErrDeadlineExceeded
+	case <-e.p.done:
+		select {
+		case f := <-e.in:
+			return f, nil
+		default:
+			return frame{}, ErrClosed
+		}
+	}
+}
+
+func (e *PipeEnd) Ping(payload []byte) error {
+	return e.deliver(frame{opPing, append([]byte(nil), payload...)}, e.deadline(&e.writeDeadline))
+}
+
+func (e *PipeEnd) SetPongHandler(h func(payload []byte)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onPong = h
+}
+
+func (e *PipeEnd) SetReadDeadline(t time.Time) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.readDeadline = t
+	return nil
+}
+
+func (e *PipeEnd) SetWriteDeadline(t time.Time) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.writeDeadline = t
+	return nil
+}
+
+func (e *PipeEnd) deadline(t *time.Time) time.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return *t
+}
+
+func (e *PipeEnd) CloseWith(code int, reason string) error {
+	_ = e.deliver(frame{opClose, closePayload(code, reason)}, time.Now().Add(closeTimeout))
+	return e.Close()
+}
+
+func (e *PipeEnd) Close() error {
+	e.p.once.Do(func() { close(e.p.done) })
+	return nil
+}
+
+func (e *PipeEnd) RemoteAddr() net.Addr {
+	return e.addr
+}
+
+// after returns a channel that fires at deadline, or never when it is zero.
+func after(deadline time.Time) (<-chan time.Time, func() bool) {
+	if deadline.IsZero() {
+		return nil, func() bool { return false }
+	}
+	t := time.NewTimer(time.Until(deadline))
+	return t.C, t.Stop
+}
+
+type pipeAddr string
+
+func (a pipeAddr) Network() string { return "pipe" }
+func (a pipeAddr) String() string  { return string(a) }
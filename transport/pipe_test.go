@@ -0,0 +1,137 @@
+package transport
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestPipeDeliversInOrder(t *testing.T) {
+	a, b := Pipe()
+	sent := []struct {
+		kind Kind
+		msg  []byte
+	}{
+		{Text, []byte(`{"type":"hello"}`)},
+		{Binary, []byte{1, 2, 3}},
+		{Binary, nil},
+		{Text, []byte(`{"type":"bye"}`)},
+	}
+
+	for _, m := range sent {
+		if err := a.Send(m.kind, m.msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, m := range sent {
+		kind, msg, err := b.Receive()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kind != m.kind || !bytes.Equal(msg, m.msg) {
+			t.Fatalf("message %d: %d %q, want %d %q", i, kind, msg, m.kind, m.msg)
+		}
+	}
+
+	if got := a.Stats(); got.MessagesSent != 4 || got.BytesSent != 33 {
+		t.Fatalf("sender stats %+v", got)
+	}
+	if got := b.Stats(); got.MessagesReceived != 4 || got.BytesReceived != 33 {
+		t.Fatalf("receiver stats %+v", got)
+	}
+}
+
+func TestPipeSendCopiesMessage(t *testing.T) {
+	a, b := Pipe()
+	msg := []byte{1}
+	if err := a.Send(Binary, msg); err != nil {
+		t.Fatal(err)
+	}
+	msg[0] = 2
+
+	if _, got, _ := b.Receive(); got[0] != 1 {
+		t.Fatalf("received %v after the sender reused its buffer", got)
+	}
+}
+
+func TestPipePingIsAnsweredWhileReceiving(t *testing.T) {
+	a, b := Pipe()
+	pongs := make(chan []byte, 1)
+	a.SetPongHandler(func(payload []byte) { pongs <- payload })
+
+	if err := a.Ping([]byte("42")); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Send(Text, []byte("after")); err != nil {
+		t.Fatal(err)
+	}
+	// b answers the ping on its way to the message, the pong reaches
+	// a's handler once a reads
+	if _, msg, err := b.Receive(); err != nil || string(msg) != "after" {
+		t.Fatalf("received %q, %v", msg, err)
+	}
+	if err := b.Send(Text, []byte("reply")); err != nil {
+		t.Fatal(err)
+	}
+	if _, msg, err := a.Receive(); err != nil || string(msg) != "reply" {
+		t.Fatalf("received %q, %v", msg, err)
+	}
+
+	select {
+	case payload := <-pongs:
+		if string(payload) != "42" {
+			t.Fatalf("pong carried %q", payload)
+		}
+	default:
+		t.Fatal("no pong")
+	}
+	if got := b.Stats(); got.MessagesReceived != 1 {
+		t.Fatalf("heartbeats counted as messages: %+v", got)
+	}
+}
+
+func TestPipeCloseWithArrivesAfterEarlierMessages(t *testing.T) {
+	a, b := Pipe()
+	for _, msg := range []string{"one", "two"} {
+		if err := a.Send(Text, []byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.CloseWith(ClosePolicyViolation, "bad input"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"one", "two"} {
+		if _, msg, err := b.Receive(); err != nil || string(msg) != want {
+			t.Fatalf("received %q, %v, want %q", msg, err, want)
+		}
+	}
+
+	_, _, err := b.Receive()
+	var ce *CloseError
+	if !errors.As(err, &ce) || ce.Code != ClosePolicyViolation || ce.Reason != "bad input" {
+		t.Fatalf("err %v, want the close code and reason", err)
+	}
+	if !IsUnexpectedClose(err) {
+		t.Fatal("policy violation not reported as unexpected")
+	}
+
+	if _, _, err := b.Receive(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("err %v after close, want ErrClosed", err)
+	}
+	if err := b.Send(Text, []byte("late")); !errors.Is(err, ErrClosed) {
+		t.Fatalf("send after close: %v", err)
+	}
+}
+
+func TestPipeReadDeadline(t *testing.T) {
+	_, b := Pipe()
+	if err := b.SetReadDeadline(time.Now().Add(10 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := b.Receive(); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("err %v, want deadline exceeded", err)
+	}
+}
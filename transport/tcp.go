@@ -0,0 +1,141 @@
+package transport
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// MaxFrameSize bounds the frames a TCP transport reads, larger ones fail the connection.
+const MaxFrameSize = 1 << 20
+
+var (
+	ErrFrameSize = errors.New("frame size out of range")
+	ErrFrameType = errors.New("unknown frame type")
+)
+
+// TCP frames messages for native clients on a raw stream:
+// [4 bytes length LE][1 byte frame type][payload], the length counts the type byte.
+// Frame types are the websocket opcodes, close frames carry [2 bytes code BE][reason]
+// and the payload of a ping comes back in a pong.
+type TCP struct {
+	conn net.Conn
+	r    *bufio.Reader
+	counters
+
+	writeMu sync.Mutex // control frames are written from the reading goroutine too
+
+	pongMu sync.Mutex
+	onPong func([]byte)
+}
+
+func NewTCP(conn net.Conn) *TCP {
+	return &TCP{conn: conn, r: bufio.NewReader(conn)}
+}
+
+func (t *TCP) Send(kind Kind, msg []byte) error {
+	if err := t.writeFrame(byte(kind), msg); err != nil {
+		return err
+	}
+	t.sent(len(msg))
+	return nil
+}
+
+func (t *TCP) writeFrame(op byte, payload []byte) error {
+	if len(payload)+1 > MaxFrameSize {
+		return ErrFrameSize
+	}
+
+	var header [5]byte
+	binary.LittleEndian.PutUint32(header[:4], uint32(len(payload)+1))
+	header[4] = op
+
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	bufs := net.Buffers{header[:], payload}
+	_, err := bufs.WriteTo(t.conn)
+	return err
+}
+
+func (t *TCP) Receive() (Kind, []byte, error) {
+	for {
+		op, payload, err := t.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch op {
+		case opText, opBinary:
+			t.received(len(payload))
+			return Kind(op), payload, nil
+		case opPing:
+			if err := t.writeFrame(opPong, payload); err != nil {
+				return 0, nil, err
+			}
+		case opPong:
+			t.pongMu.Lock()
+			h := t.onPong
+			t.pongMu.Unlock()
+			if h != nil {
+				h(payload)
+			}
+		case opClose:
+			return 0, nil, parseClose(payload)
+		default:
+			return 0, nil, ErrFrameType
+		}
+	}
+}
+
+func (t *TCP) readFrame() (byte, []byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(t.r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	size := binary.LittleEndian.Uint32(header[:])
+	if size == 0 || size > MaxFrameSize {
+		return 0, nil, ErrFrameSize
+	}
+
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(t.r, buf); err != nil {
+		return 0, nil, err
+	}
+	return buf[0], buf[1:], nil
+}
+
+func (t *TCP) Ping(payload []byte) error {
+	return t.writeFrame(opPing, payload)
+}
+
+func (t *TCP) SetPongHandler(h func(payload []byte)) {
+	t.pongMu.Lock()
+	defer t.pongMu.Unlock()
+	t.onPong = h
+}
+
+func (t *TCP) SetReadDeadline(d time.Time) error {
+	return t.conn.SetReadDeadline(d)
+}
+
+func (t *TCP) SetWriteDeadline(d time.Time) error {
+	return t.conn.SetWriteDeadline(d)
+}
+
+func (t *TCP) CloseWith(code int, reason string) error {
+	_ = t.conn.SetWriteDeadline(time.Now().Add(closeTimeout))
+	_ = t.writeFrame(opClose, closePayload(code, reason))
+	return t.conn.Close()
+}
+
+func (t *TCP) Close() error {
+	return t.conn.Close()
+}
+
+func (t *TCP) RemoteAddr() net.Addr {
+	return t.conn.RemoteAddr()
+}
@@ -0,0 +1,140 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func tcpPair(t *testing.T) (*TCP, *TCP) {
+	t.Helper()
+	a, b := net.Pipe()
+	t.Cleanup(func() {
+		a.Close()
+		b.Close()
+	})
+	return NewTCP(a), NewTCP(b)
+}
+
+func TestTCPFraming(t *testing.T) {
+	a, b := tcpPair(t)
+	sent := []struct {
+		kind Kind
+		msg  []byte
+	}{
+		{Text, []byte(`{"type":"hello"}`)},
+		{Binary, bytes.Repeat([]byte{7}, 4096)},
+		{Binary, nil},
+	}
+
+	go func() {
+		for _, m := range sent {
+			if err := a.Send(m.kind, m.msg); err != nil {
+				return
+			}
+		}
+		a.CloseWith(CloseGoingAway, "shutdown")
+	}()
+
+	for i, m := range sent {
+		kind, msg, err := b.Receive()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kind != m.kind || !bytes.Equal(msg, m.msg) {
+			t.Fatalf("message %d: %d with %d bytes, want %d with %d", i, kind, len(msg), m.kind, len(m.msg))
+		}
+	}
+
+	_, _, err := b.Receive()
+	var ce *CloseError
+	if !errors.As(err, &ce) || ce.Code != CloseGoingAway || ce.Reason != "shutdown" {
+		t.Fatalf("err %v, want the close code and reason", err)
+	}
+	if IsUnexpectedClose(err) {
+		t.Fatal("going away reported as unexpected")
+	}
+}
+
+func TestTCPFrameLimits(t *testing.T) {
+	header := func(size uint32, op byte) []byte {
+		buf := binary.LittleEndian.AppendUint32(nil, size)
+		return append(buf, op)
+	}
+
+	tests := []struct {
+		name string
+		raw  []byte
+		want error
+	}{
+		{"empty frame", header(0, opBinary)[:4], ErrFrameSize},
+		{"over the limit", header(MaxFrameSize+1, opBinary), ErrFrameSize},
+		{"unknown type", header(1, 3), ErrFrameType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, conn := net.Pipe()
+			defer raw.Close()
+			defer conn.Close()
+			go raw.Write(tt.raw)
+
+			if _, _, err := NewTCP(conn).Receive(); !errors.Is(err, tt.want) {
+				t.Fatalf("err %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	t.Run("largest frame", func(t *testing.T) {
+		a, b := tcpPair(t)
+		msg := make([]byte, MaxFrameSize-1)
+		go a.Send(Binary, msg)
+		if _, got, err := b.Receive(); err != nil || len(got) != len(msg) {
+			t.Fatalf("received %d bytes, %v", len(got), err)
+		}
+	})
+
+	t.Run("send over the limit", func(t *testing.T) {
+		a, _ := tcpPair(t)
+		if err := a.Send(Binary, make([]byte, MaxFrameSize)); !errors.Is(err, ErrFrameSize) {
+			t.Fatalf("err %v, want %v", err, ErrFrameSize)
+		}
+	})
+}
+
+func TestTCPPingIsAnsweredWhileReceiving(t *testing.T) {
+	a, b := tcpPair(t)
+	pongs := make(chan []byte, 1)
+	a.SetPongHandler(func(payload []byte) { pongs <- payload })
+
+	// net.Pipe is unbuffered, so every write needs the other side reading
+	go func() {
+		if _, _, err := b.Receive(); err == nil {
+			b.Send(Text, []byte("reply"))
+		}
+	}()
+	go func() {
+		if a.Ping([]byte("42")) == nil {
+			a.Send(Text, []byte("after"))
+		}
+	}()
+
+	if err := a.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if _, msg, err := a.Receive(); err != nil || string(msg) != "reply" {
+		t.Fatalf("received %q, %v", msg, err)
+	}
+
+	select {
+	case payload := <-pongs:
+		if string(payload) != "42" {
+			t.Fatalf("pong carried %q", payload)
+		}
+	default:
+		t.Fatal("no pong")
+	}
+}
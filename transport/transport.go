@@ -0,0 +1,130 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// Kind tells binary messages from the text messages carrying JSON events.
+// The values are the websocket opcodes.
+type Kind uint8
+
+const (
+	Text   Kind = 1
+	Binary Kind = 2
+)
+
+// Frame types of the transports that do their own framing, also websocket opcodes.
+const (
+	opText   = byte(Text)
+	opBinary = byte(Binary)
+	opClose  = 8
+	opPing   = 9
+	opPong   = 10
+)
+
+// Close codes every transport understands, the values websockets use.
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	ClosePolicyViolation = 1008
+)
+
+// closeTimeout bounds how long a close message may take to write.
+const closeTimeout = time.Second
+
+var ErrClosed = errors.New("transport closed")
+
+// CloseError is returned by Receive once the peer closed with a code.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("closed with code %d: %s", e.Code, e.Reason)
+}
+
+// IsUnexpectedClose reports whether err is the peer closing with a code
+// other than CloseNormal and CloseGoingAway.
+func IsUnexpectedClose(err error) bool {
+	var ce *CloseError
+	return errors.As(err, &ce) && ce.Code != CloseNormal && ce.Code != CloseGoingAway
+}
+
+// Stats counts the messages and payload bytes a transport carried, heartbeats excluded.
+type Stats struct {
+	MessagesSent     uint64
+	MessagesReceived uint64
+	BytesSent        uint64
+	BytesReceived    uint64
+}
+
+// Transport carries whole messages between the server and one client.
+// Send, Ping and SetWriteDeadline must be called by one goroutine at a time,
+// so must Receive and SetReadDeadline. CloseWith and Close may be called from anywhere.
+type Transport interface {
+	Send(kind Kind, msg []byte) error
+	// Receive returns the next message. Pings are answered and pongs passed
+	// to the pong handler while it waits.
+	Receive() (Kind, []byte, error)
+
+	// Ping sends a heartbeat, the peer echoes payload back in a pong.
+	Ping(payload []byte) error
+	SetPongHandler(h func(payload []byte))
+
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+
+	// CloseWith tells the peer why it is being disconnected, then closes.
+	CloseWith(code int, reason string) error
+	Close() error
+
+	RemoteAddr() net.Addr
+	Stats() Stats
+}
+
+// counters keeps the Stats of a transport.
+type counters struct {
+	messagesSent     atomic.Uint64
+	messagesReceived atomic.Uint64
+	bytesSent        atomic.Uint64
+	bytesReceived    atomic.Uint64
+}
+
+func (c *counters) sent(n int) {
+	c.messagesSent.Add(1)
+	c.bytesSent.Add(uint64(n))
+}
+
+func (c *counters) received(n int) {
+	c.messagesReceived.Add(1)
+	c.bytesReceived.Add(uint64(n))
+}
+
+func (c *counters) Stats() Stats {
+	return Stats{
+		MessagesSent:     c.messagesSent.Load(),
+		MessagesReceived: c.messagesReceived.Load(),
+		BytesSent:        c.bytesSent.Load(),
+		BytesReceived:    c.bytesReceived.Load(),
+	}
+}
+
+// closePayload is the body of a close frame: [2 bytes code][reason].
+func closePayload(code int, reason string) []byte {
+	buf := make([]byte, 2+len(reason))
+	buf[0], buf[1] = byte(code>>8), byte(code)
+	copy(buf[2:], reason)
+	return buf
+}
+
+func parseClose(payload []byte) *CloseError {
+	if len(payload) < 2 {
+		return &CloseError{Code: CloseNormal}
+	}
+	return &CloseError{Code: int(payload[0])<<8 | int(payload[1]), Reason: string(payload[2:])}
+}
@@ -0,0 +1,76 @@
+package transport
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebSocket adapts a gorilla websocket connection, pings and pongs are control frames.
+type WebSocket struct {
+	conn *websocket.Conn
+	counters
+
+	writeDeadline time.Time // owned by the writing goroutine
+}
+
+func NewWebSocket(conn *websocket.Conn) *WebSocket {
+	return &WebSocket{conn: conn}
+}
+
+func (w *WebSocket) Send(kind Kind, msg []byte) error {
+	if err := w.conn.WriteMessage(int(kind), msg); err != nil {
+		return err
+	}
+	w.sent(len(msg))
+	return nil
+}
+
+func (w *WebSocket) Receive() (Kind, []byte, error) {
+	msgType, msg, err := w.conn.ReadMessage()
+	if err != nil {
+		var ce *websocket.CloseError
+		if errors.As(err, &ce) {
+			return 0, nil, &CloseError{Code: ce.Code, Reason: ce.Text}
+		}
+		return 0, nil, err
+	}
+	w.received(len(msg))
+	return Kind(msgType), msg, nil
+}
+
+func (w *WebSocket) Ping(payload []byte) error {
+	return w.conn.WriteControl(websocket.PingMessage, payload, w.writeDeadline)
+}
+
+func (w *WebSocket) SetPongHandler(h func(payload []byte)) {
+	w.conn.SetPongHandler(func(data string) error {
+		h([]byte(data))
+		return nil
+	})
+}
+
+func (w *WebSocket) SetReadDeadline(t time.Time) error {
+	return w.conn.SetReadDeadline(t)
+}
+
+func (w *WebSocket) SetWriteDeadline(t time.Time) error {
+	w.writeDeadline = t
+	return w.conn.SetWriteDeadline(t)
+}
+
+func (w *WebSocket) CloseWith(code int, reason string) error {
+	msg := websocket.FormatCloseMessage(code, reason)
+	_ = w.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(closeTimeout))
+	return w.conn.Close()
+}
+
+func (w *WebSocket) Close() error {
+	return w.conn.Close()
+}
+
+func (w *WebSocket) RemoteAddr() net.Addr {
+	return w.conn.RemoteAddr()
+}
@@ -3,6 +3,7 @@ package utils
 import (
 	"html/template"
 	"net/http"
+	"sync"
 )
 
 // MessageData holds all template variables
@@ -15,12 +16,11 @@ type MessageData struct {
 	AutoShow bool   // whether to auto-show the message via JavaScript
 }
 
-var templates *template.Template
-
-func init() {
-	// Parse templates once at startup
-	templates = template.Must(template.ParseFiles("./views/message.html"))
-}
+// Parse templates once, on first use so packages importing utils
+// don't need the views directory
+var templates = sync.OnceValues(func() (*template.Template, error) {
+	return template.ParseFiles("./views/message.html")
+})
 
 func RenderMessage(w http.ResponseWriter, data MessageData) {
 	w.Header().Set("Content-Type", "text/html")
@@ -29,7 +29,10 @@ func RenderMessage(w http.ResponseWriter, data MessageData) {
 	escapedMessage := template.JSEscapeString(data.Message)
 	data.Message = escapedMessage
 	
-	err := templates.ExecuteTemplate(w, "message.html", data)
+	tmpl, err := templates()
+	if err == nil {
+		err = tmpl.ExecuteTemplate(w, "message.html", data)
+	}
 	if err != nil {
 		http.Error(w, "Error rendering template: "+err.Error(), http.StatusInternalServerError)
 	}